@@ -1,9 +0,0 @@
-module pwd_encrypt
-
-go 1.17
-
-require (
-	github.com/sirupsen/logrus v1.9.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
-)
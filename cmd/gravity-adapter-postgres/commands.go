@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	adapter_service "git.brobridge.com/gravity/gravity-adapter-postgres/pkg/adapter/service"
+	app "git.brobridge.com/gravity/gravity-adapter-postgres/pkg/app/instance"
+	"github.com/spf13/viper"
+)
+
+var (
+	UsageErr = errors.New("Invalid arguments")
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []*command{
+	{"run", "run", "Start adapter (default)", run},
+	{"validate", "validate", "Check configuration files", validate},
+	{"preflight", "preflight [source]", "Check database prerequisites", preflight},
+	{"slot", "slot status|create|drop|advance <source> [lsn]", "Manage replication slot", slot},
+	{"state", "state show [source] | state reset <source> [table]", "Inspect and reset state store", state},
+	{"snapshot", "snapshot <source> <table>", "Run initial load of table once", snapshot},
+	{"incremental-snapshot", "incremental-snapshot <source> <table>", "Snapshot table while streaming continues", incrementalSnapshot},
+	{"replay", "replay <source> <file>", "Send events recorded in capture file", replay},
+	{"encrypt", "encrypt [plaintext]", "Encrypt password with secret key", encrypt},
+	{"decrypt", "decrypt [ciphertext]", "Decrypt password", decrypt},
+	{"keygen", "keygen", "Generate secret key", keygen},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: gravity-adapter-postgres <command> [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-52s %s\n", cmd.usage, cmd.description)
+	}
+}
+
+func runCommand(args []string) error {
+
+	// Starting adapter by default
+	if len(args) == 0 {
+		return run(args)
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		usage()
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(args[1:])
+		if err == UsageErr {
+			fmt.Fprintf(os.Stderr, "Usage: gravity-adapter-postgres %s\n", cmd.usage)
+		}
+
+		return err
+	}
+
+	usage()
+
+	return fmt.Errorf("Unknown command: %s", args[0])
+}
+
+func loadSourceConfig() (*adapter_service.SourceConfig, error) {
+	return adapter_service.LoadSourceConfig(viper.GetString("source.config"))
+}
+
+func openDatabase(sourceName string) (*adapter_service.Database, *adapter_service.SourceInfo, error) {
+
+	config, err := loadSourceConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, ok := config.Sources[sourceName]
+	if !ok {
+		return nil, nil, fmt.Errorf("Source %s not found", sourceName)
+	}
+
+	err = adapter_service.ResolvePassword(sourceName, &info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	database := adapter_service.NewDatabase()
+	err = database.Open(&info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return database, &info, nil
+}
+
+func sourceNames(config *adapter_service.SourceConfig, args []string) ([]string, error) {
+
+	if len(args) > 0 {
+		if _, ok := config.Sources[args[0]]; !ok {
+			return nil, fmt.Errorf("Source %s not found", args[0])
+		}
+
+		return []string{args[0]}, nil
+	}
+
+	names := make([]string, 0, len(config.Sources))
+	for name, info := range config.Sources {
+		if info.Disabled {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func tableNames(info adapter_service.SourceInfo) []string {
+
+	tables := make([]string, 0, len(info.Tables))
+	for tableName, _ := range info.Tables {
+		tables = append(tables, tableName)
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
+
+func validate(args []string) error {
+
+	_, err := loadSourceConfig()
+	if err != nil {
+		if verr, ok := err.(*adapter_service.ConfigValidationErr); ok {
+			for _, e := range verr.Errors {
+				fmt.Println(e)
+			}
+
+			return fmt.Errorf("Found %d problem(s) in %s", len(verr.Errors), viper.GetString("source.config"))
+		}
+
+		return err
+	}
+
+	fmt.Println("Configuration is valid")
+
+	return nil
+}
+
+func preflight(args []string) error {
+
+	config, err := loadSourceConfig()
+	if err != nil {
+		return err
+	}
+
+	names, err := sourceNames(config, args)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range names {
+		database, info, err := openDatabase(name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Source %s:\n", name)
+		for _, check := range database.Preflight(info.Tables) {
+			fmt.Printf("  [%s] %s: %s\n", check.Status, check.Name, check.Message)
+			if check.Status == adapter_service.PreflightFailed {
+				failed++
+			}
+		}
+
+		database.Close()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d preflight check(s) failed", failed)
+	}
+
+	return nil
+}
+
+func slot(args []string) error {
+
+	if len(args) < 2 {
+		return UsageErr
+	}
+
+	database, info, err := openDatabase(args[1])
+	if err != nil {
+		return err
+	}
+
+	defer database.Close()
+
+	switch args[0] {
+	case "status":
+		status, err := database.GetSlotStatus()
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(status))
+		for k, _ := range status {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Printf("%s: %v\n", k, status[k])
+		}
+	case "create":
+		err := database.CreateSlot()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Slot %s was created\n", info.SlotName)
+	case "drop":
+		err := database.DropSlot()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Slot %s was dropped\n", info.SlotName)
+	case "advance":
+		lsn := ""
+		if len(args) > 2 {
+			lsn = args[2]
+		}
+
+		end, err := database.AdvanceSlot(lsn)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Slot %s was advanced to %s\n", info.SlotName, end)
+	default:
+		return UsageErr
+	}
+
+	return nil
+}
+
+func state(args []string) error {
+
+	if len(args) < 1 {
+		return UsageErr
+	}
+
+	config, err := loadSourceConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := app.NewAppInstance().OpenStateStore()
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	switch args[0] {
+	case "show":
+		names, err := sourceNames(config, args[1:])
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			sourceState, err := store.SourceState(name)
+			if err != nil {
+				return err
+			}
+
+			for _, tableName := range tableNames(config.Sources[name]) {
+				tableState, err := sourceState.GetTableState(tableName)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("%s %s initialLoaded=%t snapshotEpoch=%d\n", name, tableName, tableState.InitialLoaded, tableState.SnapshotEpoch)
+			}
+		}
+	case "reset":
+		if len(args) < 2 {
+			return UsageErr
+		}
+
+		name := args[1]
+		info, ok := config.Sources[name]
+		if !ok {
+			return fmt.Errorf("Source %s not found", name)
+		}
+
+		tables := tableNames(info)
+		if len(args) > 2 {
+			if _, ok := info.Tables[args[2]]; !ok {
+				return fmt.Errorf("Table %s is not configured in source %s", args[2], name)
+			}
+
+			tables = []string{args[2]}
+		}
+
+		sourceState, err := store.SourceState(name)
+		if err != nil {
+			return err
+		}
+
+		for _, tableName := range tables {
+			err := sourceState.ResetTable(tableName)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s %s was reset\n", name, tableName)
+		}
+	default:
+		return UsageErr
+	}
+
+	return nil
+}
+
+func snapshot(args []string) error {
+
+	if len(args) != 2 {
+		return UsageErr
+	}
+
+	a := app.NewAppInstance()
+
+	return a.Snapshot(args[0], args[1])
+}
+
+func incrementalSnapshot(args []string) error {
+
+	if len(args) != 2 {
+		return UsageErr
+	}
+
+	database, info, err := openDatabase(args[0])
+	if err != nil {
+		return err
+	}
+
+	defer database.Close()
+
+	if _, ok := info.Tables[args[1]]; !ok {
+		return fmt.Errorf("Table %s is not configured in source %s", args[1], args[0])
+	}
+
+	// Snapshot is taken by adapter which is consuming slot
+	err = database.SignalIncrementalSnapshot(args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Incremental snapshot of %s was requested\n", args[1])
+
+	return nil
+}
+
+func replay(args []string) error {
+
+	if len(args) != 2 {
+		return UsageErr
+	}
+
+	a := app.NewAppInstance()
+
+	return a.Replay(args[0], args[1])
+}
+
+func readArgument(args []string, prompt string) (string, error) {
+
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	// Reading from stdin to keep secret out of shell history
+	fmt.Fprint(os.Stderr, prompt)
+	reader := bufio.NewReader(os.Stdin)
+	text, err := reader.ReadString('\n')
+	if err != nil && len(text) == 0 {
+		return "", err
+	}
+
+	return strings.TrimRight(text, "\r\n"), nil
+}
+
+func encrypt(args []string) error {
+
+	plaintext, err := readArgument(args, "Password: ")
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := adapter_service.EncryptSecret(plaintext)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(ciphertext)
+
+	return nil
+}
+
+func decrypt(args []string) error {
+
+	ciphertext, err := readArgument(args, "Ciphertext: ")
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := adapter_service.DecryptSecret(ciphertext)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(plaintext)
+
+	return nil
+}
+
+func keygen(args []string) error {
+
+	secretKey, err := adapter_service.GenerateSecretKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(secretKey)
+
+	return nil
+}
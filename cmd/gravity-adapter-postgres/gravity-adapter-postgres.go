@@ -26,7 +26,7 @@ func init() {
 
 	log.SetLevel(debugLevel)
 
-	fmt.Printf("Debug level is set to \"%s\"\n", debugLevel.String())
+	fmt.Fprintf(os.Stderr, "Debug level is set to \"%s\"\n", debugLevel.String())
 
 	// From the environment
 	viper.SetEnvPrefix("GRAVITY_ADAPTER_POSTGRES")
@@ -66,19 +66,23 @@ func init() {
 
 func main() {
 
+	err := runCommand(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+}
+
+func run(args []string) error {
+
 	// Initializing application
 	a := app.NewAppInstance()
 
 	err := a.Init()
 	if err != nil {
-		log.Fatal(err)
-		return
+		return err
 	}
 
 	// Starting application
-	err = a.Run()
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
+	return a.Run()
 }
@@ -8,6 +8,7 @@ require (
 	github.com/cfsghost/parallel-chunked-flow v0.0.7
 	github.com/jmoiron/sqlx v1.3.4
 	github.com/json-iterator/go v1.1.12
+	github.com/klauspost/compress v1.17.11
 	github.com/lib/pq v1.10.1
 	github.com/nats-io/nats.go v1.37.0
 	github.com/sirupsen/logrus v1.9.3
@@ -29,7 +30,6 @@ require (
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/snappy v0.0.3 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
-	github.com/klauspost/compress v1.17.11 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/magiconair/properties v1.8.1 // indirect
@@ -2,12 +2,13 @@ package adapter
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/app"
-	"github.com/BrobridgeOrg/broton"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/nats-io/nats.go"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -16,7 +17,9 @@ var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 type Adapter struct {
 	app        app.App
-	storeMgr   *broton.Broton
+	store      StateStore
+	sink       Sink
+	control    *nats.Subscription
 	sm         *SourceManager
 	clientName string
 }
@@ -33,6 +36,54 @@ func NewAdapter(a app.App) *Adapter {
 
 func (adapter *Adapter) Init() error {
 
+	err := adapter.prepare()
+	if err != nil {
+		return err
+	}
+
+	err = adapter.sm.Initialize()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// Control commands come from gravity
+	viper.SetDefault("control.enabled", false)
+	connector := adapter.app.GetAdapterConnector()
+	if viper.GetBool("control.enabled") && connector != nil {
+		err = adapter.startControl(connector)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Snapshot runs initial load of specific table once without watching changes
+func (adapter *Adapter) Snapshot(sourceName string, tableName string) error {
+
+	err := adapter.prepare()
+	if err != nil {
+		return err
+	}
+
+	return adapter.sm.Snapshot(sourceName, tableName)
+}
+
+// Replay sends events which were recorded in capture file
+func (adapter *Adapter) Replay(sourceName string, r io.Reader) error {
+
+	err := adapter.prepare()
+	if err != nil {
+		return err
+	}
+
+	return adapter.sm.Replay(sourceName, r)
+}
+
+func (adapter *Adapter) prepare() error {
+
 	// Using hostname (pod name) by default
 	host, err := os.Hostname()
 	if err != nil {
@@ -44,34 +95,47 @@ func (adapter *Adapter) Init() error {
 
 	adapter.clientName = fmt.Sprintf("gravity_adapter_postgres-%s", host)
 
-	// Initializing store manager
+	// Initializing state store
 	viper.SetDefault("store.enabled", false)
 	enabled := viper.GetBool("store.enabled")
 	if enabled {
-		viper.SetDefault("store.path", "./store")
-		options := broton.NewOptions()
-		options.DatabasePath = viper.GetString("store.path")
-
-		log.WithFields(log.Fields{
-			"path": options.DatabasePath,
-		}).Info("Initializing store")
-		broton, err := broton.NewBroton(options)
+		store, err := OpenStateStore(adapter.app)
 		if err != nil {
 			return err
 		}
 
-		adapter.storeMgr = broton
+		adapter.store = store
 	}
 
-	err = adapter.sm.Initialize()
+	// Initializing sink for local runs
+	sink, err := openSharedSink()
 	if err != nil {
-		log.Error(err)
 		return err
 	}
 
+	adapter.sink = sink
+
 	return nil
 }
 
 func (adapter *Adapter) Uninit() error {
-	return adapter.sm.Uninit()
+
+	if adapter.control != nil {
+		adapter.control.Unsubscribe()
+	}
+
+	err := adapter.sm.Uninit()
+	if err != nil {
+		return err
+	}
+
+	if adapter.store != nil {
+		adapter.store.Close()
+	}
+
+	if adapter.sink != nil {
+		return adapter.sink.Close()
+	}
+
+	return nil
 }
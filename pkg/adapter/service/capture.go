@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultCapturePath = "./capture"
+)
+
+// CaptureRecord is a line of capture file. The first line carries primary keys of tables,
+// partitions are recorded once they were resolved, the others are raw rows from replication slot.
+type CaptureRecord struct {
+	LSN         string              `json:"lsn,omitempty"`
+	XID         string              `json:"xid,omitempty"`
+	Data        string              `json:"data,omitempty"`
+	PrimaryKeys map[string][]string `json:"primaryKeys,omitempty"`
+	Partitions  map[string]string   `json:"partitions,omitempty"`
+}
+
+// Capture appends raw output of logical decoding to file
+type Capture struct {
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func CaptureEnabled() bool {
+	viper.SetDefault("capture.enabled", false)
+	return viper.GetBool("capture.enabled")
+}
+
+// OpenCapture opens capture file of source which is <capture.path>/<source>.ndjson
+func OpenCapture(sourceName string) (*Capture, error) {
+
+	viper.SetDefault("capture.path", DefaultCapturePath)
+	dir := viper.GetString("capture.path")
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, sourceName+".ndjson"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Capture{
+		file:   f,
+		writer: bufio.NewWriter(f),
+	}, nil
+}
+
+func (c *Capture) write(record *CaptureRecord) error {
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, err = c.writer.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return c.writer.WriteByte('\n')
+}
+
+func (c *Capture) WritePrimaryKeys(primaryKeys map[string][]string) error {
+	return c.write(&CaptureRecord{
+		PrimaryKeys: primaryKeys,
+	})
+}
+
+// WritePartition records partition which was resolved, so that replay doesn't need database
+func (c *Capture) WritePartition(partition string, tableName string) error {
+	return c.write(&CaptureRecord{
+		Partitions: map[string]string{
+			partition: tableName,
+		},
+	})
+}
+
+// WriteRow writes row which was scanned from pg_logical_slot_get_changes
+func (c *Capture) WriteRow(row map[string]interface{}) error {
+
+	lsn, ok := row["lsn"]
+	if !ok {
+		lsn = row["location"]
+	}
+
+	return c.write(&CaptureRecord{
+		LSN:  columnString(lsn),
+		XID:  columnString(row["xid"]),
+		Data: columnString(row["data"]),
+	})
+}
+
+func (c *Capture) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.writer.Flush()
+}
+
+func (c *Capture) Close() error {
+
+	err := c.Flush()
+	if err != nil {
+		return err
+	}
+
+	return c.file.Close()
+}
+
+func columnString(value interface{}) string {
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+
+	return fmt.Sprint(value)
+}
+
+// ReadCapture calls fn with every record of capture file
+func ReadCapture(r io.Reader, fn func(*CaptureRecord) error) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record CaptureRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		err = fn(&record)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Row converts record to the row which processEvent accepts
+func (record *CaptureRecord) Row() map[string]interface{} {
+	return map[string]interface{}{
+		"lsn":  []byte(record.LSN),
+		"xid":  []byte(record.XID),
+		"data": record.Data,
+	}
+}
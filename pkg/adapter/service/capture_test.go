@@ -0,0 +1,56 @@
+package adapter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+
+	viper.Set("capture.path", t.TempDir())
+	defer viper.Set("capture.path", DefaultCapturePath)
+
+	capture, err := OpenCapture("my_postgres")
+	assert.Nil(t, err)
+
+	err = capture.WritePrimaryKeys(map[string][]string{
+		"public.users": []string{"id"},
+	})
+	assert.Nil(t, err)
+
+	err = capture.WriteRow(map[string]interface{}{
+		"lsn":  []byte("0/16B3748"),
+		"xid":  []byte("562"),
+		"data": "table public.users: INSERT: id[integer]:1 name[text]:'fred'",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, capture.Close())
+
+	f, err := os.Open(filepath.Join(viper.GetString("capture.path"), "my_postgres.ndjson"))
+	assert.Nil(t, err)
+	defer f.Close()
+
+	records := make([]CaptureRecord, 0)
+	err = ReadCapture(f, func(record *CaptureRecord) error {
+		records = append(records, *record)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, []string{"id"}, records[0].PrimaryKeys["public.users"])
+
+	// Replayed row must be accepted by parser in the same way as live one
+	database := NewDatabase()
+	events, err := database.processEvent(records[1].Row())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+
+	e := events[0]
+	assert.Equal(t, InsertOperation, e.Operation)
+	assert.Equal(t, "public.users", e.Table)
+	assert.Equal(t, "0/16B3748-562", e.LastLSN)
+}
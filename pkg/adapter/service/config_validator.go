@@ -0,0 +1,389 @@
+package adapter
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	tableNamePattern  = regexp.MustCompile(`^([a-z_][a-z0-9_$]*|"([^"]|"")+")\.([a-z_][a-z0-9_$]*|"([^"]|"")+")$`)
+	slotNamePattern   = regexp.MustCompile(`^[a-z0-9_]{1,63}$`)
+)
+
+type ConfigError struct {
+	Path    string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+type ConfigValidationErr struct {
+	Errors []error
+}
+
+func (e *ConfigValidationErr) Error() string {
+
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return fmt.Sprintf("Invalid source config:\n  %s", strings.Join(messages, "\n  "))
+}
+
+func configPath(parent string, key string) string {
+
+	if !identifierPattern.MatchString(key) {
+		key = fmt.Sprintf("[%q]", key)
+	} else if len(parent) > 0 {
+		key = "." + key
+	}
+
+	return parent + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+
+	keys := make([]string, 0, len(m))
+	for k, _ := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// invalidValue tells whether value at path itself doesn't match its type
+func invalidValue(path string, errs []error) bool {
+
+	for _, err := range errs {
+		if e, ok := err.(*ConfigError); ok && e.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportedPath tells whether path or its parent was reported already
+func reportedPath(path string, errs []error) bool {
+
+	for _, err := range errs {
+		e, ok := err.(*ConfigError)
+		if !ok {
+			continue
+		}
+
+		if path == e.Path || strings.HasPrefix(path, e.Path+".") || strings.HasPrefix(path, e.Path+"[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateSchema walks raw JSON document with the type it is going to be decoded into. Values
+// of wrong type are replaced with null, so that the rest of document can still be decoded.
+func validateSchema(path string, value interface{}, t reflect.Type) []error {
+
+	errs := make([]error, 0)
+	report := func(format string, args ...interface{}) []error {
+		return append(errs, &ConfigError{
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if value == nil {
+		return errs
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return validateSchema(path, value, t.Elem())
+
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return report("expected object")
+		}
+
+		fields := make(map[string]reflect.StructField, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if len(name) > 0 && name != "-" {
+				fields[name] = field
+			}
+		}
+
+		for _, key := range sortedKeys(obj) {
+
+			// Keys start with "//" are comments
+			if strings.HasPrefix(key, "//") {
+				continue
+			}
+
+			field, ok := fields[key]
+			if !ok {
+				errs = append(errs, &ConfigError{
+					Path:    configPath(path, key),
+					Message: "unknown field",
+				})
+				continue
+			}
+
+			fieldPath := configPath(path, key)
+			fieldErrs := validateSchema(fieldPath, obj[key], field.Type)
+			if invalidValue(fieldPath, fieldErrs) {
+				obj[key] = nil
+			}
+
+			errs = append(errs, fieldErrs...)
+		}
+
+	case reflect.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return report("expected object")
+		}
+
+		for _, key := range sortedKeys(obj) {
+			valuePath := configPath(path, key)
+			valueErrs := validateSchema(valuePath, obj[key], t.Elem())
+			if invalidValue(valuePath, valueErrs) {
+				obj[key] = nil
+			}
+
+			errs = append(errs, valueErrs...)
+		}
+
+	case reflect.Slice:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return report("expected array")
+		}
+
+		for i, v := range arr {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			itemErrs := validateSchema(itemPath, v, t.Elem())
+			if invalidValue(itemPath, itemErrs) {
+				arr[i] = nil
+			}
+
+			errs = append(errs, itemErrs...)
+		}
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			return report("expected string")
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return report("expected boolean")
+		}
+
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		n, ok := value.(float64)
+		if !ok {
+			return report("expected integer")
+		}
+
+		if n != float64(int64(n)) {
+			return report("expected integer")
+		}
+
+	case reflect.Float64:
+		if _, ok := value.(float64); !ok {
+			return report("expected number")
+		}
+	}
+
+	return errs
+}
+
+// ValidateSourceConfig reports every problem of configuration
+func ValidateSourceConfig(config *SourceConfig) []error {
+
+	errs := make([]error, 0)
+	report := func(path string, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Replication slots are shared by the whole cluster
+	slots := make(map[string]string)
+
+	names := make([]string, 0, len(config.Sources))
+	for name, _ := range config.Sources {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		info := config.Sources[name]
+		path := configPath(configPath("", "sources"), name)
+
+		if info.Disabled {
+			continue
+		}
+
+		if len(info.Host) == 0 {
+			report(configPath(path, "host"), "required")
+		}
+
+		if info.Port < 1 || info.Port > 65535 {
+			report(configPath(path, "port"), "must be between 1 and 65535")
+		}
+
+		if len(info.DBName) == 0 {
+			report(configPath(path, "dbname"), "required")
+		}
+
+		if info.PasswordSecret != nil {
+			errs = append(errs, validateSecretSource(configPath(path, "passwordSecret"), info.PasswordSecret)...)
+		}
+
+		if info.TLS != nil {
+			errs = append(errs, validateSourceTLS(configPath(path, "tls"), info.TLS)...)
+
+			if strings.Contains(info.Param, "ssl") {
+				report(configPath(path, "param"), "ssl parameters must not be mixed with tls settings")
+			}
+		}
+
+		if len(info.Domain) > 0 && !domainPattern.MatchString(info.Domain) {
+			report(configPath(path, "domain"), "may only contain letters, numbers, underscore and hyphen")
+		}
+
+		if info.LeaderElection != nil {
+			errs = append(errs, validateSourceLeaderElection(configPath(path, "leaderElection"), info.LeaderElection)...)
+		}
+
+		if info.Signal != nil {
+			errs = append(errs, validateSourceSignal(configPath(path, "signal"), info.Signal)...)
+		}
+
+		if info.Heartbeat != nil {
+			errs = append(errs, validateSourceHeartbeat(configPath(path, "heartbeat"), info.Heartbeat)...)
+		}
+
+		if info.SlotMonitor != nil {
+			errs = append(errs, validateSlotMonitor(configPath(path, "slotMonitor"), info.SlotMonitor)...)
+		}
+
+		if info.Messages != nil {
+			errs = append(errs, validateSourceMessages(configPath(path, "messages"), info.Messages)...)
+		}
+
+		if info.InitialLoadBatchSize < 0 {
+			report(configPath(path, "initialLoadBatchSize"), "must not be negative")
+		}
+
+		if info.Interval < 0 {
+			report(configPath(path, "interval"), "must not be negative")
+		}
+
+		if info.MaxChangesPerRead < 0 {
+			report(configPath(path, "maxChangesPerRead"), "must not be negative")
+		}
+
+		if info.MaxBytesPerRead < 0 {
+			report(configPath(path, "maxBytesPerRead"), "must not be negative")
+		}
+
+		if info.SnapshotChunkSize < 0 {
+			report(configPath(path, "snapshotChunkSize"), "must not be negative")
+		}
+
+		if len(info.SlotName) == 0 {
+			report(configPath(path, "slotName"), "required")
+		} else if !slotNamePattern.MatchString(info.SlotName) {
+			report(configPath(path, "slotName"), "may only contain lower case letters, numbers and underscore, up to 63 characters")
+		} else {
+			cluster := fmt.Sprintf("%s:%d/%s", info.Host, info.Port, info.SlotName)
+			if another, ok := slots[cluster]; ok {
+				report(configPath(path, "slotName"), "slot %s is already used by source %s", info.SlotName, another)
+			} else {
+				slots[cluster] = name
+			}
+		}
+
+		if len(info.Tables) == 0 {
+			report(configPath(path, "tables"), "at least one table is required")
+		}
+
+		tables := make([]string, 0, len(info.Tables))
+		for tableName, _ := range info.Tables {
+			tables = append(tables, tableName)
+		}
+
+		sort.Strings(tables)
+
+		for _, tableName := range tables {
+			table := info.Tables[tableName]
+			tablePath := configPath(configPath(path, "tables"), tableName)
+
+			if !tableNamePattern.MatchString(tableName) {
+				report(tablePath, "invalid table name, expected schema.table in lower case or double-quoted identifiers")
+			}
+
+			if !validateUnchangedToast(table.UnchangedToast) {
+				report(configPath(tablePath, "unchangedToast"), "must be one of omit, flag and fetch")
+			}
+
+			if len(table.Domain) > 0 && !domainPattern.MatchString(table.Domain) {
+				report(configPath(tablePath, "domain"), "may only contain letters, numbers, underscore and hyphen")
+			}
+
+			if table.RateLimit < 0 {
+				report(configPath(tablePath, "rateLimit"), "must not be negative")
+			}
+
+			for i, column := range table.Columns {
+				if len(column) == 0 {
+					report(fmt.Sprintf("%s[%d]", configPath(tablePath, "columns"), i), "column name must not be empty")
+				}
+			}
+
+			if table.Outbox != nil {
+				errs = append(errs, validateSourceOutbox(configPath(tablePath, "outbox"), table.Outbox)...)
+
+				// Event names come from outbox rows
+				if table.Outbox.SkipRow {
+					continue
+				}
+			}
+
+			eventsPath := configPath(tablePath, "events")
+			if info.InitialLoad && len(table.Events.Snapshot) == 0 {
+				report(configPath(eventsPath, "snapshot"), "event name is required when initialLoad is enabled")
+			}
+
+			if len(table.Events.Create) == 0 {
+				report(configPath(eventsPath, "create"), "event name is required")
+			}
+
+			if len(table.Events.Update) == 0 {
+				report(configPath(eventsPath, "update"), "event name is required")
+			}
+
+			if len(table.Events.Delete) == 0 {
+				report(configPath(eventsPath, "delete"), "event name is required")
+			}
+		}
+	}
+
+	return errs
+}
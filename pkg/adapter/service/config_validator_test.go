@@ -0,0 +1,174 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSourceConfig(t *testing.T) {
+
+	source := `{
+		"sources": {
+			"my_postgres": {
+				"host": "127.0.0.1",
+				"port": 5432,
+				"dbname": "gravity",
+				"initialLoad": true,
+				"//_comment_interval": "query interval unit: seconds",
+				"interval": 1,
+				"slotName": "regression_slot",
+				"tables": {
+					"public.account": {
+						"events": {
+							"snapshot": "accountInitialized",
+							"create": "accountCreated",
+							"update": "accountUpdated",
+							"delete": "accountDeleted"
+						}
+					}
+				}
+			}
+		}
+	}`
+
+	config, err := ParseSourceConfig([]byte(source))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, "regression_slot", config.Sources["my_postgres"].SlotName)
+}
+
+func TestParseSourceConfigSyntaxError(t *testing.T) {
+
+	_, err := ParseSourceConfig([]byte(`{"sources": {`))
+
+	assert.NotNil(t, err)
+}
+
+func TestParseSourceConfigSchema(t *testing.T) {
+
+	source := `{
+		"sources": {
+			"my_postgres": {
+				"host": "127.0.0.1",
+				"port": "5432",
+				"dbname": "gravity",
+				"initalLoad": true,
+				"slotName": "Regression-Slot",
+				"tables": {
+					"public.account": {
+						"event": {}
+					}
+				}
+			}
+		}
+	}`
+
+	_, err := ParseSourceConfig([]byte(source))
+
+	verr, ok := err.(*ConfigValidationErr)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	// The rest of config is validated as well
+	assert.Equal(t, 7, len(verr.Errors))
+	assert.Equal(t, "sources.my_postgres.initalLoad: unknown field", verr.Errors[0].Error())
+	assert.Equal(t, "sources.my_postgres.port: expected integer", verr.Errors[1].Error())
+	assert.Equal(t, `sources.my_postgres.tables["public.account"].event: unknown field`, verr.Errors[2].Error())
+	assert.Equal(t, "sources.my_postgres.slotName: may only contain lower case letters, numbers and underscore, up to 63 characters", verr.Errors[3].Error())
+	assert.Equal(t, `sources.my_postgres.tables["public.account"].events.create: event name is required`, verr.Errors[4].Error())
+}
+
+func TestValidateSourceConfig(t *testing.T) {
+
+	config := &SourceConfig{
+		Sources: map[string]SourceInfo{
+			"a": {
+				Host:        "127.0.0.1",
+				Port:        5432,
+				DBName:      "gravity",
+				InitialLoad: true,
+				SlotName:    "regression_slot",
+				Tables: map[string]SourceTable{
+					"account": {
+						Events: SourceTableEvents{
+							Create: "accountCreated",
+							Update: "accountUpdated",
+							Delete: "accountDeleted",
+						},
+					},
+				},
+			},
+			"b": {
+				Port:     5432,
+				DBName:   "gravity",
+				SlotName: "regression_slot",
+				Tables: map[string]SourceTable{
+					`public."Account"`: {
+						Events: SourceTableEvents{
+							Create: "accountCreated",
+							Update: "accountUpdated",
+							Delete: "accountDeleted",
+						},
+					},
+				},
+			},
+			"c": {
+				Host:     "127.0.0.1",
+				Port:     5432,
+				DBName:   "gravity",
+				SlotName: "regression_slot",
+			},
+		},
+	}
+
+	errs := ValidateSourceConfig(config)
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	assert.Equal(t, []string{
+		`sources.a.tables.account: invalid table name, expected schema.table in lower case or double-quoted identifiers`,
+		`sources.a.tables.account.events.snapshot: event name is required when initialLoad is enabled`,
+		`sources.b.host: required`,
+		`sources.c.slotName: slot regression_slot is already used by source a`,
+		`sources.c.tables: at least one table is required`,
+	}, messages)
+}
+
+func TestValidateSourceTLS(t *testing.T) {
+
+	errs := validateSourceTLS("sources.a.tls", &SourceTLS{
+		Mode:       "require",
+		CertFile:   "/certs/client.crt",
+		ServerName: "db.example.com",
+	})
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	assert.Equal(t, []string{
+		"sources.a.tls.keyFile: required when certFile is set",
+		"sources.a.tls.serverName: only takes effect with verify-full mode",
+	}, messages)
+
+	params := (&SourceTLS{
+		Mode:     "verify-full",
+		CAFile:   "/certs/ca.crt",
+		CertFile: "/certs/client.crt",
+		KeyFile:  "/certs/client.key",
+	}).Params()
+
+	assert.Equal(t, "verify-full", params.Get("sslmode"))
+	assert.Equal(t, "/certs/ca.crt", params.Get("sslrootcert"))
+	assert.Equal(t, "/certs/client.crt", params.Get("sslcert"))
+	assert.Equal(t, "/certs/client.key", params.Get("sslkey"))
+}
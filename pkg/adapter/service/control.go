@@ -0,0 +1,301 @@
+package adapter
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/gravity"
+	"github.com/nats-io/nats.go"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	SnapshotCommand     = "snapshot"
+	StopSnapshotCommand = "stop-snapshot"
+	PauseCommand        = "pause"
+	ResumeCommand       = "resume"
+	StateCommand        = "state"
+
+	DefaultControlSubject = "$GVT.%s.ADAPTER.POSTGRES.CONTROL"
+)
+
+// SourceSignal is table which receives control commands through replication slot,
+// it requires columns id, type and data.
+type SourceSignal struct {
+	Table string `json:"table"`
+}
+
+type ControlCommand struct {
+	Source  string   `json:"source"`
+	Command string   `json:"command"`
+	Tables  []string `json:"tables"`
+}
+
+type ControlReply struct {
+	Success bool                   `json:"success"`
+	Source  string                 `json:"source"`
+	Command string                 `json:"command"`
+	Tables  []string               `json:"tables,omitempty"`
+	State   map[string]interface{} `json:"state,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// Execute runs control command and reports tables which were affected
+func (source *Source) Execute(cmd *ControlCommand) *ControlReply {
+
+	reply := &ControlReply{
+		Success: true,
+		Source:  source.name,
+		Command: cmd.Command,
+		Tables:  make([]string, 0),
+	}
+
+	fail := func(err error) {
+		reply.Success = false
+		reply.Error = err.Error()
+	}
+
+	switch cmd.Command {
+	case SnapshotCommand:
+		tables := cmd.Tables
+		if len(tables) == 0 {
+			tables = source.tableNames()
+		}
+
+		for _, tableName := range tables {
+			err := source.database.RequestIncrementalSnapshot(tableName)
+			if err != nil {
+				fail(err)
+				continue
+			}
+
+			reply.Tables = append(reply.Tables, tableName)
+		}
+	case StopSnapshotCommand:
+		reply.Tables = source.database.snapshotter.stop(cmd.Tables)
+	case PauseCommand:
+		atomic.StoreInt32(&source.database.suspended, 1)
+		reply.Tables = source.tableNames()
+	case ResumeCommand:
+		atomic.StoreInt32(&source.database.suspended, 0)
+		reply.Tables = source.tableNames()
+	case StateCommand:
+		reply.Tables = source.tableNames()
+		reply.State = source.currentState()
+	default:
+		fail(fmt.Errorf("Unknown command: %s", cmd.Command))
+	}
+
+	fields := log.Fields{
+		"source":  source.name,
+		"command": cmd.Command,
+		"tables":  reply.Tables,
+		"success": reply.Success,
+	}
+
+	if reply.State != nil {
+		fields["state"] = reply.State
+	}
+
+	if reply.Success {
+		log.WithFields(fields).Info("Control command was executed")
+	} else {
+		log.WithFields(fields).Error("Control command failed: ", reply.Error)
+	}
+
+	return reply
+}
+
+func (source *Source) tableNames() []string {
+
+	tables := make([]string, 0, len(source.tables))
+	for tableName, _ := range source.tables {
+		tables = append(tables, tableName)
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
+
+func (source *Source) currentState() map[string]interface{} {
+
+	running, queued := source.database.snapshotter.status()
+
+	state := map[string]interface{}{
+		"paused":   source.database.isPaused(),
+		"pending":  atomic.LoadInt64(&source.pending),
+		"snapshot": running,
+		"queued":   queued,
+	}
+
+	if source.leader != nil {
+		state["leader"] = source.leader.IsLeader()
+		state["fencingToken"] = source.leader.Token()
+	}
+
+	tables := make(map[string]interface{}, len(source.tables))
+	for tableName, _ := range source.tables {
+		tableInfo := source.database.getTableInfo(tableName)
+		table := map[string]interface{}{
+			"initialLoaded": tableInfo.initialLoaded,
+			"snapshotEpoch": tableInfo.snapshotEpoch,
+		}
+
+		if progress, ok := source.database.progress.get(tableName); ok {
+			table["initialLoad"] = progress
+		}
+
+		tables[tableName] = table
+	}
+
+	state["tables"] = tables
+
+	return state
+}
+
+func (database *Database) isSignal(tableName string) bool {
+	return database.dbInfo.Signal != nil && database.dbInfo.Signal.Table == tableName
+}
+
+// peekSignals reads signal table from slot without consuming it, changes of other tables
+// are left in slot while source was paused.
+func (database *Database) peekSignals() {
+
+	if database.dbInfo.Signal == nil || database.db == nil {
+		return
+	}
+
+	rows, err := database.db.Queryx(`SELECT * FROM pg_logical_slot_peek_changes($1, NULL, NULL) WHERE data LIKE $2`,
+		database.dbInfo.SlotName,
+		"table "+database.dbInfo.Signal.Table+":%",
+	)
+	if err != nil {
+		log.Error("slot: ", err)
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		event := make(map[string]interface{})
+		err := rows.MapScan(event)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+
+		database.processPeekedSignal(event)
+	}
+}
+
+// processPeekedSignal executes signal once, it is skipped when slot is consumed later
+func (database *Database) processPeekedSignal(event map[string]interface{}) {
+
+	lsn := eventLSN(event)
+	if database.peekedSignals[lsn] {
+		return
+	}
+
+	database.processEvent(event)
+	database.peekedSignals[lsn] = true
+}
+
+// processSignal executes command which was inserted into signal table
+func (database *Database) processSignal(operation string, data map[string]interface{}) {
+
+	// Nothing to control without source, e.g. replaying capture file
+	if operation != "INSERT" || database.source == nil {
+		return
+	}
+
+	cmd := &ControlCommand{
+		Source: database.source.name,
+	}
+
+	cmd.Command, _ = data["type"].(string)
+
+	if payload, ok := data["data"].(string); ok && len(payload) > 0 {
+		err := json.Unmarshal([]byte(payload), cmd)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"id": data["id"],
+			}).Error("Invalid signal: ", err)
+			return
+		}
+
+		cmd.Source = database.source.name
+	}
+
+	log.WithFields(log.Fields{
+		"id": data["id"],
+	}).Info("Received signal")
+
+	database.source.Execute(cmd)
+}
+
+// startControl serves control commands which are sent as NATS requests
+func (adapter *Adapter) startControl(connector *gravity.AdapterConnector) error {
+
+	viper.SetDefault("control.subject", fmt.Sprintf(DefaultControlSubject, connector.GetDomain()))
+	subject := viper.GetString("control.subject")
+
+	sub, err := connector.Subscribe(subject, func(msg *nats.Msg) {
+
+		var cmd ControlCommand
+		err := json.Unmarshal(msg.Data, &cmd)
+		if err != nil {
+			reply, _ := json.Marshal(&ControlReply{
+				Error: err.Error(),
+			})
+			msg.Respond(reply)
+			return
+		}
+
+		// Another instance might run the source
+		source, ok := adapter.sm.sources[cmd.Source]
+		if !ok {
+			return
+		}
+
+		// Only leader replies, since standby doesn't consume slot
+		if source.leader != nil && !source.leader.IsLeader() {
+			return
+		}
+
+		reply, _ := json.Marshal(source.Execute(&cmd))
+		msg.Respond(reply)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"subject": subject,
+	}).Info("Listening for control commands")
+
+	adapter.control = sub
+
+	return nil
+}
+
+func validateSourceSignal(path string, signal *SourceSignal) []error {
+
+	errs := make([]error, 0)
+
+	if len(signal.Table) == 0 {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, "table"),
+			Message: "required",
+		})
+	} else if !tableNamePattern.MatchString(signal.Table) {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, "table"),
+			Message: "invalid table name, expected schema.table in lower case or double-quoted identifiers",
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,100 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceExecute(t *testing.T) {
+
+	source := &Source{
+		name: "a",
+		tables: map[string]SourceTable{
+			"public.users":  SourceTable{},
+			"public.orders": SourceTable{},
+		},
+		database: NewDatabase(),
+	}
+
+	source.database.source = source
+	source.database.tables = source.tables
+	source.database.dbInfo.Signal = &SourceSignal{
+		Table: "public.gravity_signal",
+	}
+
+	reply := source.Execute(&ControlCommand{Command: PauseCommand})
+	assert.True(t, reply.Success)
+	assert.Equal(t, []string{"public.orders", "public.users"}, reply.Tables)
+	assert.True(t, source.database.isPaused())
+
+	// Signal table is consumed through slot
+	events, err := source.database.processEvent(map[string]interface{}{
+		"lsn":  []byte("0/16B3748"),
+		"xid":  []byte("590"),
+		"data": `table public.gravity_signal: INSERT: id[text]:'1' type[text]:'resume' data[text]:'{}'`,
+	})
+	assert.Equal(t, EmptyEventTypeErr, err)
+	assert.Nil(t, events)
+	assert.False(t, source.database.isPaused())
+
+	// Table without primary key can't be snapshotted incrementally
+	reply = source.Execute(&ControlCommand{Command: SnapshotCommand, Tables: []string{"public.users"}})
+	assert.False(t, reply.Success)
+	assert.Equal(t, 0, len(reply.Tables))
+
+	reply = source.Execute(&ControlCommand{Command: StateCommand})
+	assert.True(t, reply.Success)
+	assert.Equal(t, false, reply.State["paused"])
+
+	reply = source.Execute(&ControlCommand{Command: "reboot"})
+	assert.False(t, reply.Success)
+	assert.Equal(t, "Unknown command: reboot", reply.Error)
+}
+
+func TestSignalPauseResume(t *testing.T) {
+
+	source := &Source{
+		name: "a",
+		tables: map[string]SourceTable{
+			"public.users": SourceTable{},
+		},
+		database: NewDatabase(),
+	}
+
+	source.database.source = source
+	source.database.tables = source.tables
+	source.database.dbInfo.Signal = &SourceSignal{
+		Table: "public.gravity_signal",
+	}
+
+	signal := func(lsn string, command string) map[string]interface{} {
+		return map[string]interface{}{
+			"lsn":  []byte(lsn),
+			"xid":  []byte("590"),
+			"data": `table public.gravity_signal: INSERT: id[text]:'` + lsn + `' type[text]:'` + command + `' data[text]:'{}'`,
+		}
+	}
+
+	// Pausing through slot
+	_, err := source.database.processEvent(signal("0/16B3748", PauseCommand))
+	assert.Equal(t, EmptyEventTypeErr, err)
+	assert.True(t, source.database.isPaused())
+
+	// Slot is only peeked while paused, resume signal is executed once
+	source.database.processPeekedSignal(signal("0/16B3750", ResumeCommand))
+	assert.False(t, source.database.isPaused())
+
+	source.Execute(&ControlCommand{Command: PauseCommand})
+	source.database.processPeekedSignal(signal("0/16B3750", ResumeCommand))
+	assert.True(t, source.database.isPaused())
+
+	// Consuming slot later doesn't execute it again
+	_, err = source.database.processEvent(signal("0/16B3750", ResumeCommand))
+	assert.Equal(t, EmptyEventTypeErr, err)
+	assert.True(t, source.database.isPaused())
+	assert.Equal(t, 0, len(source.database.peekedSignals))
+
+	source.database.processPeekedSignal(signal("0/16B3758", ResumeCommand))
+	assert.False(t, source.database.isPaused())
+}
@@ -1,16 +1,22 @@
 package adapter
 
 import (
+	"database/sql"
 	"fmt"
-	"strconv"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	DefaultMaxOpenConns = 10
+	DefaultMaxIdleConns = 10
+)
+
 var eventPool = sync.Pool{
 	New: func() interface{} {
 		return make(map[string]interface{})
@@ -18,27 +24,72 @@ var eventPool = sync.Pool{
 }
 
 type DatabaseInfo struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	DbName   string `json:"db_name"`
-	Param    string `json:"param"`
-	SlotName string `json:"slotName"`
-	Interval int    `json:"interval"`
+	Host        string           `json:"host"`
+	Port        int              `json:"port"`
+	Username    string           `json:"username"`
+	Password    string           `json:"password"`
+	DbName      string           `json:"db_name"`
+	Param       string           `json:"param"`
+	SlotName    string           `json:"slotName"`
+	Interval    int              `json:"interval"`
+	Heartbeat   *SourceHeartbeat `json:"heartbeat"`
+	SlotMonitor *SlotMonitor     `json:"slotMonitor"`
+
+	Messages map[string]SourceMessage `json:"messages"`
+
+	MaxChangesPerRead int   `json:"maxChangesPerRead"`
+	MaxBytesPerRead   int64 `json:"maxBytesPerRead"`
+	SnapshotChunkSize int   `json:"snapshotChunkSize"`
+
+	Signal *SourceSignal `json:"signal"`
+
+	InitialLoadProgress *SourceInitialLoadProgress `json:"initialLoadProgress"`
 }
 
 type Database struct {
 	db          *sqlx.DB
 	dbInfo      *DatabaseInfo
+	tableMutex  sync.RWMutex
 	tableInfo   map[string]tableInfo
 	updateEvent map[int64]CDCEvent
 	source      *Source
+	capture     *Capture
+	tables      map[string]SourceTable
+	partitions  *partitionResolver
+	snapshotter *incrementalSnapshotter
+	progress    *progressTracker
 	stopping    bool
+	paused      int32
+	suspended   int32
+
+	// Signals which were executed by peeking slot while source was paused
+	peekedSignals map[string]bool
 }
 
 type tableInfo struct {
-	initialLoaded bool
+	initialLoaded    bool
+	snapshotEpoch    int64
+	snapshotPosition string
+	primaryKeys      []string
+}
+
+// getTableInfo returns a copy of table information, it is also read by control and status requests
+func (database *Database) getTableInfo(tableName string) tableInfo {
+
+	database.tableMutex.RLock()
+	defer database.tableMutex.RUnlock()
+
+	return database.tableInfo[tableName]
+}
+
+func (database *Database) updateTableInfo(tableName string, fn func(*tableInfo)) {
+
+	database.tableMutex.Lock()
+	defer database.tableMutex.Unlock()
+
+	info := database.tableInfo[tableName]
+	fn(&info)
+	database.tableInfo[tableName] = info
 }
 
 func NewDatabase() *Database {
@@ -46,13 +97,30 @@ func NewDatabase() *Database {
 		dbInfo:      &DatabaseInfo{},
 		tableInfo:   make(map[string]tableInfo, 0),
 		updateEvent: make(map[int64]CDCEvent, 0),
+		partitions:  newPartitionResolver(),
+		snapshotter: newIncrementalSnapshotter(),
+		progress:    newProgressTracker(),
 		stopping:    false,
+
+		peekedSignals: make(map[string]bool),
 	}
 }
 
 func (database *Database) Connect(source *Source) error {
 
-	info := source.info
+	err := database.Open(source.info)
+	if err != nil {
+		return err
+	}
+
+	database.source = source
+	database.tables = source.tables
+
+	return nil
+}
+
+func (database *Database) Open(info *SourceInfo) error {
+
 	log.WithFields(log.Fields{
 		"host":     info.Host,
 		"port":     info.Port,
@@ -61,42 +129,80 @@ func (database *Database) Connect(source *Source) error {
 		"param":    info.Param,
 	}).Info("Connecting to database")
 
-	connStr := fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?%s",
-		info.Username,
-		info.Password,
-		info.Host,
-		info.Port,
-		info.DBName,
-		info.Param,
-	)
+	connURL := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(info.Username, info.Password),
+		Host:     fmt.Sprintf("%s:%d", info.Host, info.Port),
+		Path:     "/" + info.DBName,
+		RawQuery: info.Param,
+	}
 
-	// Open database
-	db, err := sqlx.Open("postgres", connStr)
-	if err != nil {
-		log.Error(err)
-		return err
+	dialer := &sourceDialer{}
+	if info.TLS != nil {
+
+		// Make sure certificates are usable before connecting
+		err := info.TLS.Verify()
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+
+		params := info.TLS.Params().Encode()
+		if len(connURL.RawQuery) > 0 {
+			params = connURL.RawQuery + "&" + params
+		}
+
+		connURL.RawQuery = params
+
+		// Server certificate is verified with server name rather than the host we connect to
+		if len(info.TLS.ServerName) > 0 {
+			connURL.Host = fmt.Sprintf("%s:%d", info.TLS.ServerName, info.Port)
+			dialer.address = fmt.Sprintf("%s:%d", info.Host, info.Port)
+		}
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(10)
+	// Open database
+	db := sqlx.NewDb(sql.OpenDB(&sourceConnector{
+		dsn:    connURL.String(),
+		dialer: dialer,
+	}), "postgres")
+
+	db.SetMaxOpenConns(DefaultMaxOpenConns)
+	db.SetMaxIdleConns(DefaultMaxIdleConns)
 
 	database.dbInfo = &DatabaseInfo{
-		Host:     info.Host,
-		Port:     info.Port,
-		Username: info.Username,
-		DbName:   info.DBName,
-		Param:    info.Param,
-		SlotName: info.SlotName,
-		Interval: info.Interval,
+		Host:        info.Host,
+		Port:        info.Port,
+		Username:    info.Username,
+		DbName:      info.DBName,
+		Param:       info.Param,
+		SlotName:    info.SlotName,
+		Interval:    info.Interval,
+		Heartbeat:   info.Heartbeat,
+		SlotMonitor: info.SlotMonitor,
+		Messages:    info.Messages,
+
+		MaxChangesPerRead: info.MaxChangesPerRead,
+		MaxBytesPerRead:   info.MaxBytesPerRead,
+		SnapshotChunkSize: info.SnapshotChunkSize,
+		Signal:            info.Signal,
+
+		InitialLoadProgress: info.InitialLoadProgress,
 	}
 
 	database.db = db
-	database.source = source
+
+	if info.TLS != nil && len(info.TLS.files()) > 0 {
+		go database.watchCertificates(info.TLS)
+	}
 
 	return nil
 }
 
+func (database *Database) Close() error {
+	return database.db.Close()
+}
+
 func (database *Database) GetConnection() *sqlx.DB {
 	return database.db
 }
@@ -105,21 +211,43 @@ func (database *Database) WatchEvents(tables map[string]SourceTable, interval in
 
 	log.Info("Start watch event.")
 
+	limit := newReadLimit(database.dbInfo.MaxChangesPerRead, database.dbInfo.MaxBytesPerRead)
+
+	sourceName := ""
+	if database.source != nil {
+		sourceName = database.source.name
+	}
+
 	go func() {
-		for {
+		for !database.stopping {
+
+			// Slot is kept as it is while source was paused, only signals are peeked so that it can be resumed
+			if atomic.LoadInt32(&database.suspended) == 1 {
+				database.peekSignals()
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// Reading only when events of previous read were handled
+			database.waitForCapacity(limit.maxChanges)
+
 			// query
-			sqlStr := fmt.Sprintf(`SELECT * FROM pg_logical_slot_get_changes('%s', NULL, NULL);`,
+			n := limit.next()
+			sqlStr := fmt.Sprintf(`SELECT * FROM pg_logical_slot_get_changes('%s', NULL, %d);`,
 				database.dbInfo.SlotName,
+				n,
 			)
 
 			//log.Info(sqlStr)
-			rows, err := database.db.Queryx(sqlStr)
+			rows, release, err := database.querySlot(sqlStr)
 			if err != nil {
 				log.Error("slot: ", err)
 				time.Sleep(time.Duration(database.dbInfo.Interval) * time.Second)
 				continue
 			}
 
+			changes := 0
+			bytes := int64(0)
 			for rows.Next() {
 				// parse data
 				event := eventPool.Get().(map[string]interface{})
@@ -129,9 +257,21 @@ func (database *Database) WatchEvents(tables map[string]SourceTable, interval in
 					continue
 				}
 
-				var e *CDCEvent
+				changes++
+				if data, ok := event["data"].(string); ok {
+					bytes += int64(len(data))
+				}
+
+				if database.capture != nil {
+					err := database.capture.WriteRow(event)
+					if err != nil {
+						log.Error("capture: ", err)
+					}
+				}
+
+				var events []*CDCEvent
 				// Prepare CDC event
-				e, err = database.processEvent(event)
+				events, err = database.processEvent(event)
 				if err != nil {
 					if err == UnsupportEventTypeErr {
 						log.Debug("Skip event ...")
@@ -147,11 +287,33 @@ func (database *Database) WatchEvents(tables map[string]SourceTable, interval in
 					}
 				}
 
-				fn(e)
+				for _, e := range events {
+					fn(e)
+				}
 				eventPool.Put(event)
 
 			}
-			rows.Close()
+			release()
+
+			if database.capture != nil {
+				database.capture.Flush()
+			}
+
+			limit.observe(changes, bytes)
+			slotChangesRead.Add(float64(changes), sourceName)
+			slotBytesRead.Add(float64(bytes), sourceName)
+
+			// Reading next chunk once the previous one was published
+			snapshotting := database.stepIncrementalSnapshot()
+
+			// Reading again immediately while backlog remains
+			if changes >= n || snapshotting {
+				log.WithFields(log.Fields{
+					"changes": changes,
+					"bytes":   bytes,
+				}).Debug("Backlog remains in slot")
+				continue
+			}
 
 			// delay
 			time.Sleep(time.Duration(database.dbInfo.Interval) * time.Second)
@@ -162,16 +324,29 @@ func (database *Database) WatchEvents(tables map[string]SourceTable, interval in
 
 }
 
-func (database *Database) DoInitialLoad(sourceName string, tables map[string]SourceTable, fn func(*CDCEvent), initialLoadBatchSize int, interval int) error {
+// querySlot reads slot on session of leader if leader election was enabled
+func (database *Database) querySlot(sqlStr string) (*sqlx.Rows, func(), error) {
 
-	if initialLoadBatchSize == 0 {
-		initialLoadBatchSize = 100000
+	if database.source != nil && database.source.leader != nil {
+		return database.source.leader.querySlot(sqlStr)
 	}
 
+	rows, err := database.db.Queryx(sqlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rows, func() {
+		rows.Close()
+	}, nil
+}
+
+func (database *Database) DoInitialLoad(sourceName string, tables map[string]SourceTable, fn func(*CDCEvent), initialLoadBatchSize int, interval int) error {
+
 	regenSlot := false
 	for tableName, _ := range tables {
 		//get tableInfo
-		tableInfo := database.tableInfo[tableName]
+		tableInfo := database.getTableInfo(tableName)
 
 		// if scn not equal 0 than don't do it.
 		if tableInfo.initialLoaded {
@@ -179,148 +354,102 @@ func (database *Database) DoInitialLoad(sourceName string, tables map[string]Sou
 		}
 		regenSlot = true
 
-		tableInfo = database.tableInfo[tableName]
-
-		//get total amount
-		sqlStr := fmt.Sprintf(`SELECT COUNT(*) FROM %s`,
-			tableName,
-		)
-
-		log.Debug(sqlStr)
-		var t interface{}
-		var total int64
-		err := database.db.Get(&t, sqlStr)
+		err := database.SnapshotTable(sourceName, tableName, fn, initialLoadBatchSize, interval)
 		if err != nil {
-			log.Error(err)
+			return err
 		}
 
-		if float64Val, ok := t.(float64); ok {
-			total = int64(float64Val)
-
+		if database.stopping {
+			return nil
 		}
-		if int64Val, ok := t.(int64); ok {
-			total = int64Val
-		}
-		if strVal, ok := t.(string); ok {
-			if t, err := strconv.ParseInt(strVal, 10, 64); err == nil {
-				total = t
-			}
-		}
-
-		bulkSize := int64(initialLoadBatchSize)
-		remainder := total % bulkSize
-		amountByBulk := (total - remainder) / bulkSize
+	}
 
-		// query
-		// begin transation
-		tx, err := database.db.Beginx()
+	if regenSlot {
+		// Re-gen slot
+		err := database.regenerateSlot()
 		if err != nil {
 			log.Error(err)
 		}
-		defer tx.Rollback()
+		log.Info("Re-generate slot: ", database.dbInfo.SlotName)
+	}
 
-		// generate cursor
-		_, err = tx.Exec(fmt.Sprintf("DECLARE pagination_cursor CURSOR FOR SELECT * FROM %s ORDER BY ctid", tableName))
-		if err != nil {
-			log.Error("cursor: ", err)
-		}
+	return nil
 
-		for l := int64(1); l <= amountByBulk+1; l++ {
-			if l <= amountByBulk {
-				from := (l - 1) * bulkSize
-				log.Info(fmt.Sprintf("Processing %s initialLoad from %d to %d total: %d", tableName, from, from+bulkSize, total))
-			} else if remainder != 0 {
-				from := (l - 1) * bulkSize
-				log.Info(fmt.Sprintf("Processing %s initialLoad from %d to %d total: %d", tableName, from, from+remainder, total))
-			} else {
-				continue
-			}
-			rows, err := tx.Queryx(fmt.Sprintf("FETCH FORWARD %d FROM pagination_cursor", bulkSize))
-			if err != nil {
-				log.Error("Fetch :", err)
-			}
+}
 
-			i := 0
-			for rows.Next() {
-				// parse data
-				event := eventPool.Get().(map[string]interface{})
-				err := rows.MapScan(event)
-				if err != nil {
-					log.Error("mapScan: ", err)
-					continue
-				}
+func (database *Database) SnapshotTable(sourceName string, tableName string, fn func(*CDCEvent), initialLoadBatchSize int, interval int) error {
 
-				// Prepare CDC event
-				e := database.processSnapshotEvent(tableName, event)
-				i += 1
-				e.LastLSN = fmt.Sprintf("%s-%s-%d-%d", sourceName, tableName, l, i)
-				fn(e)
-				eventPool.Put(event)
-			}
+	if initialLoadBatchSize == 0 {
+		initialLoadBatchSize = DefaultInitialLoadBatchSize
+	}
 
-			if err := rows.Err(); err != nil {
-				if database.stopping {
-					return nil
-				}
-				log.Error("Initialization Error: ", err)
-				time.Sleep(time.Duration(interval) * time.Second)
-			}
+	r := database.newSnapshotReader(tableName, initialLoadBatchSize)
 
-			rows.Close()
-		}
+	// Estimate from statistics, counting rows of huge table takes too long
+	blocks, estimated, err := r.blocks()
+	if err != nil {
+		log.Error(err)
+	}
 
-		// close cursor
-		_, err = tx.Exec("CLOSE pagination_cursor")
-		if err != nil {
-			log.Error("close cursor: ", err)
-		}
+	info := database.getTableInfo(tableName)
+	total, exact := database.countRows(r, estimated)
+	progress := database.progress.start(tableName, len(info.snapshotPosition) > 0, total, exact, time.Now())
+	reportProgress(sourceName, tableName, progress)
+	database.saveProgress(tableName, progress)
 
-		// commit transation
-		err = tx.Commit()
-		if err != nil {
-			log.Error("commit: ", err)
-		}
+	if e := database.processProgressMarker(sourceName, tableName, InitialLoadStartedMarker, progress); e != nil {
+		fn(e)
+	}
 
-		initialLoadStatusCol := fmt.Sprintf("%s-%s", sourceName, tableName)
-		err = database.source.store.PutInt64("status", []byte(initialLoadStatusCol), 1)
-		if err != nil {
-			log.Error("Failed to update status")
-		}
-		log.Info(tableName, " initialLoad done.")
+	if len(r.keys) > 0 {
+		database.snapshotByKey(sourceName, r, fn, interval)
+	} else {
+		log.WithFields(log.Fields{
+			"table": tableName,
+		}).Warn("Table has no primary key, reading by ranges of blocks")
 
+		database.snapshotByBlock(sourceName, r, fn, blocks, estimated, interval)
 	}
 
-	if regenSlot {
-		// Re-gen slot
-		err := database.regenerateSlot()
+	if database.stopping {
+		return nil
+	}
+
+	progress = database.progress.complete(tableName, time.Now())
+	reportProgress(sourceName, tableName, progress)
+	database.saveProgress(tableName, progress)
+
+	if e := database.processProgressMarker(sourceName, tableName, InitialLoadCompletedMarker, progress); e != nil {
+		fn(e)
+	}
+
+	if database.source.state != nil {
+		err = database.source.state.SetInitialLoaded(tableName, info.snapshotEpoch)
 		if err != nil {
-			log.Error(err)
+			log.Error("Failed to update status")
 		}
-		log.Info("Re-generate slot: ", database.dbInfo.SlotName)
 	}
 
-	return nil
+	// Epoch is increased along with state, so that next snapshot has new message IDs
+	database.updateTableInfo(tableName, func(t *tableInfo) {
+		t.initialLoaded = true
+		t.snapshotEpoch = info.snapshotEpoch + 1
+		t.snapshotPosition = ""
+	})
+
+	log.Info(tableName, " initialLoad done.")
 
+	return nil
 }
 
 func (database *Database) regenerateSlot() error {
 	// drop
 	log.Debug("Drop Slot")
-	sqlStr := fmt.Sprintf(`SELECT pg_drop_replication_slot('%s')`,
-		database.dbInfo.SlotName,
-	)
-	database.db.Exec(sqlStr)
+	database.DropSlot()
 
 	// create
 	log.Debug("Create Slot")
-	sqlStr = fmt.Sprintf(`SELECT * FROM pg_create_logical_replication_slot('%s', 'test_decoding')`,
-		database.dbInfo.SlotName,
-	)
-	_, err := database.db.Exec(sqlStr)
-	if err != nil {
-		return err
-	}
-	return nil
+	return database.CreateSlot()
 }
 
 func (database *Database) StartCDC(sourceName string, tables map[string]SourceTable, initialLoad bool, initialLoadBatchSize int, interval int, fn func(*CDCEvent)) error {
@@ -332,12 +461,20 @@ func (database *Database) StartCDC(sourceName string, tables map[string]SourceTa
 		}).Info("Received Current Process Time")
 	}
 
+	if database.dbInfo.SlotMonitor != nil {
+		go database.MonitorSlot(sourceName, database.dbInfo.SlotMonitor)
+	}
+
 	if initialLoad {
 		database.DoInitialLoad(sourceName, tables, fn, initialLoadBatchSize, interval)
 	}
 
 	go database.WatchEvents(tables, interval, fn)
 
+	if database.dbInfo.Heartbeat != nil {
+		go database.StartHeartbeat()
+	}
+
 	return nil
 
 }
@@ -0,0 +1,102 @@
+package adapter
+
+import (
+	"regexp"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	domainPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// domainOf returns domain which events of table go to, empty string means gravity.domain
+func (source *Source) domainOf(tableName string) string {
+
+	if table, ok := source.tables[tableName]; ok && len(table.Domain) > 0 {
+		return table.Domain
+	}
+
+	return source.info.Domain
+}
+
+// domains returns every domain which is used by this source
+func (source *Source) domains() []string {
+
+	domains := []string{source.info.Domain}
+	seen := map[string]bool{
+		source.info.Domain: true,
+	}
+
+	for _, table := range source.tables {
+		if len(table.Domain) == 0 || seen[table.Domain] {
+			continue
+		}
+
+		seen[table.Domain] = true
+		domains = append(domains, table.Domain)
+	}
+
+	return domains
+}
+
+// prepareGravitySinks creates sink for every domain, acknowledgements are tracked by each of them
+func (source *Source) prepareGravitySinks() error {
+
+	for _, domain := range source.domains() {
+		connector, err := source.adapter.app.GetDomainConnector(domain)
+		if err != nil {
+			return err
+		}
+
+		sink, err := NewGravitySink(connector, source.publishBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(domain) > 0 {
+			log.WithFields(log.Fields{
+				"source": source.name,
+				"domain": domain,
+			}).Info("Routing events to domain")
+		}
+
+		source.sinks[domain] = sink
+	}
+
+	return nil
+}
+
+// sinkOf returns sink which events of table go to. Local sinks don't have domains,
+// so everything goes to the default one.
+func (source *Source) sinkOf(tableName string) Sink {
+
+	if sink, ok := source.sinks[source.domainOf(tableName)]; ok {
+		return sink
+	}
+
+	return source.sinks[""]
+}
+
+func (source *Source) waitForAcks() {
+
+	for _, sink := range source.sinks {
+		err := sink.WaitForAcks()
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func (source *Source) flushSinks(timeout time.Duration) error {
+
+	for _, sink := range source.sinks {
+		err := sink.Flush(timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceDomain(t *testing.T) {
+
+	source := &Source{
+		info: &SourceInfo{
+			Domain: "tenant_a",
+		},
+		tables: map[string]SourceTable{
+			"public.orders": SourceTable{
+				Domain: "tenant_b",
+			},
+			"public.users": SourceTable{},
+		},
+		sinks: make(map[string]Sink),
+	}
+
+	assert.Equal(t, "tenant_b", source.domainOf("public.orders"))
+	assert.Equal(t, "tenant_a", source.domainOf("public.users"))
+	assert.Equal(t, "tenant_a", source.domainOf(HeartbeatPrefix))
+	assert.ElementsMatch(t, []string{"tenant_a", "tenant_b"}, source.domains())
+
+	// Local sink receives events of every domain
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	source.sinks[""] = sink
+	assert.Equal(t, sink, source.sinkOf("public.orders"))
+}
@@ -16,6 +16,10 @@ const (
 	UpdateOperation
 	DeleteOperation
 	SnapshotOperation
+	HeartbeatOperation
+	TruncateOperation
+	MessageOperation
+	ControlOperation
 )
 
 var (
@@ -30,6 +34,18 @@ type CDCEvent struct {
 	After     map[string]interface{}
 	Before    map[string]interface{}
 	LastLSN   string
+
+	// Raw payload of logical decoding message
+	Payload []byte
+
+	// Partition which the change happened in, Table is the table it belongs to
+	Partition string
+
+	// Columns which were not in WAL because of TOAST
+	UnchangedToast []string
+
+	// Values in text form of PostgreSQL, primary key is encoded with them
+	TextData map[string]string
 }
 
 var cdcEventPool = sync.Pool{
@@ -38,28 +54,75 @@ var cdcEventPool = sync.Pool{
 	},
 }
 
-func (database *Database) processEvent(event map[string]interface{}) (*CDCEvent, error) {
+func eventLSN(event map[string]interface{}) string {
+	if _, ok := event["lsn"]; ok {
+		return fmt.Sprintf("%s-%s", string(event["lsn"].([]byte)), string(event["xid"].([]byte)))
+	}
+
+	return fmt.Sprintf("%s-%s", string(event["location"].([]byte)), string(event["xid"].([]byte)))
+}
+
+func (database *Database) heartbeatEvents(event map[string]interface{}) ([]*CDCEvent, error) {
+
+	e, err := database.processHeartbeatEvent(eventLSN(event))
+	if err != nil {
+		return nil, err
+	}
+
+	return []*CDCEvent{e}, nil
+}
+
+// processEvent converts decoded row to CDC events, TRUNCATE of multiple tables produces one event per table
+func (database *Database) processEvent(event map[string]interface{}) ([]*CDCEvent, error) {
+
+	data := event["data"].(string)
+
+	// Heartbeat message
+	if database.isHeartbeat(data, "") {
+		return database.heartbeatEvents(event)
+	}
 
 	// Parse event
 	p := parser.NewParser()
-	err := p.Parse(event["data"].(string))
+	err := p.Parse(data)
 	if err != nil {
-		log.Error(event["data"].(string))
+		log.Error(data)
 		return nil, err
 	}
 
-	// Prepare CDC event
-	e := cdcEventPool.Get().(*CDCEvent)
-	e.Table = p.Table
-	e.After = p.AfterData
+	// Heartbeat table
+	if database.isHeartbeat(data, p.Table) {
+		return database.heartbeatEvents(event)
+	}
+
+	// Signal table is for controlling adapter only, signals peeked while paused were executed already
+	if database.isSignal(p.Table) {
+		lsn := eventLSN(event)
+		if database.peekedSignals[lsn] {
+			delete(database.peekedSignals, lsn)
+		} else {
+			database.processSignal(p.Operation, p.AfterData)
+		}
 
+		return nil, EmptyEventTypeErr
+	}
+
+	var operation OperationType
 	switch p.Operation {
 	case "INSERT":
-		e.Operation = InsertOperation
+		operation = InsertOperation
 	case "UPDATE":
-		e.Operation = UpdateOperation
+		operation = UpdateOperation
 	case "DELETE":
-		e.Operation = DeleteOperation
+		operation = DeleteOperation
+	case "TRUNCATE":
+		return database.processTruncateEvent(p, eventLSN(event)), nil
+	case "MESSAGE":
+		if database.isWatermark(p.Message) || database.isSnapshotSignal(p.Message) {
+			return database.processControlMessage(p.Message)
+		}
+
+		return database.processMessageEvent(p.Message, eventLSN(event))
 	case "":
 		return nil, EmptyEventTypeErr
 	default:
@@ -68,16 +131,71 @@ func (database *Database) processEvent(event map[string]interface{}) (*CDCEvent,
 		return nil, UnsupportEventTypeErr
 	}
 
-	if _, ok := event["lsn"]; ok {
-		e.LastLSN = fmt.Sprintf("%s-%s", string(event["lsn"].([]byte)), string(event["xid"].([]byte)))
-	} else {
-		e.LastLSN = fmt.Sprintf("%s-%s", string(event["location"].([]byte)), string(event["xid"].([]byte)))
+	// Prepare CDC event
+	e := cdcEventPool.Get().(*CDCEvent)
+	e.Operation = operation
+	e.Table, e.Partition = database.resolveTable(p.Table)
+	e.After = p.AfterData
+	e.TextData = p.TextData
+	e.UnchangedToast = p.UnchangedToast
+	e.Payload = nil
+	e.LastLSN = eventLSN(event)
+
+	// Row of snapshot chunk is out of date once it was changed
+	database.dropChangedRow(e)
+
+	return []*CDCEvent{e}, nil
+}
+
+func (database *Database) processTruncateEvent(p *parser.Parser, lastLSN string) []*CDCEvent {
+
+	// Truncating partitioned table shows every partition, but only one event is needed for the table
+	tables := make([]string, 0, len(p.Tables))
+	partitions := make(map[string][]string)
+	for _, tableName := range p.Tables {
+		root, partition := database.resolveTable(tableName)
+		if _, ok := partitions[root]; !ok {
+			tables = append(tables, root)
+			partitions[root] = make([]string, 0)
+		}
+
+		if len(partition) > 0 {
+			partitions[root] = append(partitions[root], partition)
+		}
+	}
+
+	events := make([]*CDCEvent, len(tables))
+	for i, tableName := range tables {
+		e := cdcEventPool.Get().(*CDCEvent)
+		e.Operation = TruncateOperation
+		e.Table = tableName
+		e.Partition = ""
+		e.Before = nil
+		e.After = map[string]interface{}{
+			"table":           tableName,
+			"tables":          p.Tables,
+			"cascade":         p.Cascade,
+			"restartIdentity": p.RestartIdentity,
+		}
+		e.UnchangedToast = nil
+		e.TextData = nil
+		e.Payload = nil
+		e.LastLSN = lastLSN
+
+		if len(partitions[tableName]) > 0 {
+			e.After["partitions"] = partitions[tableName]
+			if len(partitions[tableName]) == 1 {
+				e.Partition = partitions[tableName][0]
+			}
+		}
+
+		events[i] = e
 	}
 
-	return e, nil
+	return events
 }
 
-func (database *Database) processSnapshotEvent(tableName string, eventPayload map[string]interface{}) *CDCEvent {
+func (database *Database) processSnapshotEvent(tableName string, eventPayload map[string]interface{}, text map[string]string) *CDCEvent {
 	afterValue := make(map[string]interface{})
 	for key, value := range eventPayload {
 		afterValue[key] = value
@@ -87,6 +205,10 @@ func (database *Database) processSnapshotEvent(tableName string, eventPayload ma
 	result.Operation = SnapshotOperation
 	result.Table = tableName
 	result.After = afterValue
+	result.TextData = text
+	result.UnchangedToast = nil
+	result.Payload = nil
+	result.Partition = ""
 
 	return result
 
@@ -0,0 +1,100 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessTruncateEvent(t *testing.T) {
+
+	database := NewDatabase()
+
+	events, err := database.processEvent(map[string]interface{}{
+		"lsn":  []byte("0/16B3748"),
+		"xid":  []byte("570"),
+		"data": "table public.orders, public.order_items: TRUNCATE: cascade",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+
+	assert.Equal(t, TruncateOperation, events[0].Operation)
+	assert.Equal(t, "public.orders", events[0].Table)
+	assert.Equal(t, "public.order_items", events[1].Table)
+	assert.Equal(t, true, events[1].After["cascade"])
+	assert.Equal(t, false, events[1].After["restartIdentity"])
+	assert.Equal(t, "0/16B3748-570", events[1].LastLSN)
+}
+
+func TestProcessMessageEvent(t *testing.T) {
+
+	database := NewDatabase()
+	database.dbInfo.Messages = map[string]SourceMessage{
+		"outbox": SourceMessage{
+			Event: "orderPlaced",
+		},
+		"audit": SourceMessage{
+			Event:            "auditLogged",
+			NonTransactional: true,
+		},
+	}
+
+	row := func(data string) map[string]interface{} {
+		return map[string]interface{}{
+			"lsn":  []byte("0/16B3748"),
+			"xid":  []byte("571"),
+			"data": data,
+		}
+	}
+
+	events, err := database.processEvent(row(`message: transactional: 1 prefix: outbox, sz: 8 content:{"id":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, MessageOperation, events[0].Operation)
+	assert.Equal(t, "outbox", events[0].Table)
+	assert.Equal(t, []byte(`{"id":1}`), events[0].Payload)
+
+	// Non-transactional message is ignored unless it was enabled
+	_, err = database.processEvent(row(`message: transactional: 0 prefix: outbox, sz: 8 content:{"id":1}`))
+	assert.Equal(t, EmptyEventTypeErr, err)
+
+	events, err = database.processEvent(row(`message: transactional: 0 prefix: audit, sz: 5 content:login`))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("login"), events[0].Payload)
+
+	// Unknown prefix
+	_, err = database.processEvent(row(`message: transactional: 1 prefix: other, sz: 2 content:{}`))
+	assert.Equal(t, EmptyEventTypeErr, err)
+}
+
+func TestProcessPartitionEvent(t *testing.T) {
+
+	database := NewDatabase()
+	database.tables = map[string]SourceTable{
+		"public.measurements": SourceTable{},
+	}
+	database.partitions.set("public.measurements_2024", "public.measurements")
+	database.partitions.set("public.measurements_2025", "public.measurements")
+
+	events, err := database.processEvent(map[string]interface{}{
+		"lsn":  []byte("0/16B3748"),
+		"xid":  []byte("572"),
+		"data": "table public.measurements_2024: INSERT: id[integer]:1 value[integer]:20",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "public.measurements", events[0].Table)
+	assert.Equal(t, "public.measurements_2024", events[0].Partition)
+
+	// Truncating partitioned table emits one event for the table
+	events, err = database.processEvent(map[string]interface{}{
+		"lsn":  []byte("0/16B3750"),
+		"xid":  []byte("573"),
+		"data": "table public.measurements_2024, public.measurements_2025: TRUNCATE: (no-flags)",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "public.measurements", events[0].Table)
+	assert.Equal(t, "", events[0].Partition)
+	assert.Equal(t, []string{"public.measurements_2024", "public.measurements_2025"}, events[0].After["partitions"])
+}
@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	MessageHeartbeatMode = "message"
+	TableHeartbeatMode   = "table"
+
+	HeartbeatPrefix = "gravity_heartbeat"
+)
+
+// SourceHeartbeat writes marker into WAL periodically, so that slot keeps advancing
+// even if tables we watch are quiet.
+type SourceHeartbeat struct {
+	Interval int    `json:"interval"`
+	Mode     string `json:"mode"`
+	Table    string `json:"table"`
+	Event    string `json:"event"`
+}
+
+func (hb *SourceHeartbeat) mode() string {
+	if len(hb.Mode) == 0 {
+		return MessageHeartbeatMode
+	}
+
+	return hb.Mode
+}
+
+// StartHeartbeat writes heartbeat until database is stopping
+func (database *Database) StartHeartbeat() {
+
+	hb := database.dbInfo.Heartbeat
+
+	log.WithFields(log.Fields{
+		"mode":     hb.mode(),
+		"interval": hb.Interval,
+	}).Info("Start heartbeat")
+
+	for !database.stopping {
+		time.Sleep(time.Duration(hb.Interval) * time.Second)
+
+		err := database.writeHeartbeat()
+		if err != nil {
+			log.Error("heartbeat: ", err)
+		}
+	}
+}
+
+func (database *Database) writeHeartbeat() error {
+
+	hb := database.dbInfo.Heartbeat
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	if hb.mode() == TableHeartbeatMode {
+		sqlStr := fmt.Sprintf(`INSERT INTO %s (slot_name, updated_at) VALUES ($1, $2)
+			ON CONFLICT (slot_name) DO UPDATE SET updated_at = EXCLUDED.updated_at`, hb.Table)
+
+		_, err := database.db.Exec(sqlStr, database.dbInfo.SlotName, now)
+		return err
+	}
+
+	// Slot name is the prefix, so that sources on the same database don't consume heartbeat of each other
+	_, err := database.db.Exec(`SELECT pg_logical_emit_message(false, $1, $2)`, HeartbeatPrefix+"."+database.dbInfo.SlotName, now)
+	return err
+}
+
+// isHeartbeat checks whether decoded row is heartbeat of this source
+func (database *Database) isHeartbeat(data string, tableName string) bool {
+
+	hb := database.dbInfo.Heartbeat
+	if hb == nil {
+		return false
+	}
+
+	if hb.mode() == TableHeartbeatMode {
+		return tableName == hb.Table
+	}
+
+	// message: transactional: 0 prefix: gravity_heartbeat.<slot>, sz: 30 content:2006-01-02T15:04:05Z
+	return strings.HasPrefix(data, "message:") &&
+		strings.Contains(data, " prefix: "+HeartbeatPrefix+"."+database.dbInfo.SlotName+",")
+}
+
+func (database *Database) processHeartbeatEvent(lastLSN string) (*CDCEvent, error) {
+
+	log.WithFields(log.Fields{
+		"slot": database.dbInfo.SlotName,
+		"lsn":  lastLSN,
+	}).Debug("Received heartbeat")
+
+	// Nothing to publish, slot was advanced by consuming heartbeat anyway
+	if len(database.dbInfo.Heartbeat.Event) == 0 {
+		return nil, EmptyEventTypeErr
+	}
+
+	e := cdcEventPool.Get().(*CDCEvent)
+	e.Operation = HeartbeatOperation
+	e.Table = HeartbeatPrefix
+	e.Before = nil
+	e.UnchangedToast = nil
+	e.TextData = nil
+	e.Payload = nil
+	e.Partition = ""
+	e.After = map[string]interface{}{
+		"slot": database.dbInfo.SlotName,
+		"lsn":  lastLSN,
+		"time": time.Now().UTC(),
+	}
+	e.LastLSN = lastLSN
+
+	return e, nil
+}
+
+func validateSourceHeartbeat(path string, hb *SourceHeartbeat) []error {
+
+	errs := make([]error, 0)
+	report := func(field string, message string) {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, field),
+			Message: message,
+		})
+	}
+
+	if hb.Interval < 1 {
+		report("interval", "must be at least 1 second")
+	}
+
+	switch hb.mode() {
+	case MessageHeartbeatMode:
+		if len(hb.Table) > 0 {
+			report("table", "only takes effect with table mode")
+		}
+	case TableHeartbeatMode:
+		if len(hb.Table) == 0 {
+			report("table", "required with table mode")
+		} else if !tableNamePattern.MatchString(hb.Table) {
+			report("table", "invalid table name, expected schema.table in lower case or double-quoted identifiers")
+		}
+	default:
+		report("mode", "must be one of message and table")
+	}
+
+	return errs
+}
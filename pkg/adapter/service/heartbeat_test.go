@@ -0,0 +1,37 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatEvent(t *testing.T) {
+
+	database := NewDatabase()
+	database.dbInfo.SlotName = "my_slot"
+	database.dbInfo.Heartbeat = &SourceHeartbeat{
+		Interval: 10,
+		Event:    "postgresHeartbeat",
+	}
+
+	events, err := database.processEvent(map[string]interface{}{
+		"lsn":  []byte("0/16B3748"),
+		"xid":  []byte("0"),
+		"data": "message: transactional: 0 prefix: gravity_heartbeat.my_slot, sz: 20 content:2024-01-01T00:00:00Z",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+
+	e := events[0]
+	assert.Equal(t, HeartbeatOperation, e.Operation)
+	assert.Equal(t, "my_slot", e.After["slot"])
+
+	// Heartbeat of another source is ignored
+	_, err = database.processEvent(map[string]interface{}{
+		"lsn":  []byte("0/16B3750"),
+		"xid":  []byte("0"),
+		"data": "message: transactional: 0 prefix: gravity_heartbeat.another_slot, sz: 20 content:2024-01-01T00:00:00Z",
+	})
+	assert.Equal(t, EmptyEventTypeErr, err)
+}
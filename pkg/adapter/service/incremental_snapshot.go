@@ -0,0 +1,470 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/adapter/service/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SnapshotSignalPrefix = "gravity_snapshot"
+	WatermarkPrefix      = "gravity_watermark"
+
+	DefaultSnapshotChunkSize = 1024
+
+	// Snapshot is aborted once the same chunk failed so many times in a row
+	snapshotChunkMaxFailures = 5
+	snapshotRetryMaxDelay    = time.Minute
+
+	lowWatermark  = "low"
+	highWatermark = "high"
+)
+
+var (
+	TableNotConfiguredErr = errors.New("Table is not configured")
+)
+
+// incrementalSnapshot reads table chunk by chunk in primary key order. Every chunk is read
+// between low and high watermarks which are written into WAL, rows changed within the window
+// are dropped from chunk since their changes are newer, the rest are published at high watermark.
+type incrementalSnapshot struct {
+	id      string
+	table   string
+	keys    []string
+	lastKey []interface{}
+	chunk   int
+	window  bool
+	waiting bool
+	last    bool
+	order   []string
+	rows    map[string]snapshotRow
+
+	// Consecutive failures of chunk and when to try again
+	failures int
+	retryAt  time.Time
+}
+
+type snapshotRow struct {
+	data map[string]interface{}
+	text map[string]string
+}
+
+// add keeps row of chunk by key, key is in text form so that changes of the row can find it
+func (s *incrementalSnapshot) add(row map[string]interface{}, text map[string]string) error {
+
+	key, err := EncodePrimaryKey(s.keys, row, text)
+	if err != nil {
+		return err
+	}
+
+	s.order = append(s.order, key)
+	s.rows[key] = snapshotRow{
+		data: row,
+		text: text,
+	}
+
+	return nil
+}
+
+// incrementalSnapshotter runs snapshots one after another. Requests might come from other
+// goroutines, the others happen in goroutine which reads slot.
+type incrementalSnapshotter struct {
+	mutex   sync.Mutex
+	queue   []string
+	stopped map[string]bool
+	current *incrementalSnapshot
+}
+
+func newIncrementalSnapshotter() *incrementalSnapshotter {
+	return &incrementalSnapshotter{
+		queue:   make([]string, 0),
+		stopped: make(map[string]bool),
+	}
+}
+
+func (s *incrementalSnapshotter) setCurrent(current *incrementalSnapshot) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Stopping snapshot which was completed already takes no effect
+	if current == nil {
+		s.stopped = make(map[string]bool)
+	}
+
+	s.current = current
+}
+
+// stop cancels snapshots of tables, all of them if tables are not specified. It returns tables
+// which were running or queued.
+func (s *incrementalSnapshotter) stop(tables []string) []string {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	matched := func(tableName string) bool {
+		if len(tables) == 0 {
+			return true
+		}
+
+		for _, t := range tables {
+			if t == tableName {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	stopped := make([]string, 0)
+	queue := make([]string, 0, len(s.queue))
+	for _, tableName := range s.queue {
+		if matched(tableName) {
+			stopped = append(stopped, tableName)
+			continue
+		}
+
+		queue = append(queue, tableName)
+	}
+
+	s.queue = queue
+
+	// Running one is stopped by goroutine which reads slot
+	if s.current != nil && matched(s.current.table) {
+		s.stopped[s.current.table] = true
+		stopped = append(stopped, s.current.table)
+	}
+
+	return stopped
+}
+
+func (s *incrementalSnapshotter) takeStopped(tableName string) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.stopped[tableName] {
+		return false
+	}
+
+	delete(s.stopped, tableName)
+
+	return true
+}
+
+// status returns table which is running and tables which are queued
+func (s *incrementalSnapshotter) status() (string, []string) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	running := ""
+	if s.current != nil {
+		running = s.current.table
+	}
+
+	return running, append([]string{}, s.queue...)
+}
+
+func (s *incrementalSnapshotter) request(tableName string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queue = append(s.queue, tableName)
+}
+
+func (s *incrementalSnapshotter) next() (string, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.queue) == 0 {
+		return "", false
+	}
+
+	tableName := s.queue[0]
+	s.queue = s.queue[1:]
+
+	return tableName, true
+}
+
+// SignalIncrementalSnapshot asks adapter which is consuming slot to snapshot table
+func (database *Database) SignalIncrementalSnapshot(tableName string) error {
+	_, err := database.db.Exec(`SELECT pg_logical_emit_message(true, $1, $2)`, SnapshotSignalPrefix+"."+database.dbInfo.SlotName, tableName)
+	return err
+}
+
+// RequestIncrementalSnapshot queues snapshot of table, it starts once previous one is completed
+func (database *Database) RequestIncrementalSnapshot(tableName string) error {
+
+	if _, ok := database.tables[tableName]; !ok {
+		return fmt.Errorf("%v: %s", TableNotConfiguredErr, tableName)
+	}
+
+	if len(database.GetPrimaryKeys(tableName)) == 0 {
+		return fmt.Errorf("%v: %s", MissingPrimaryKeyErr, tableName)
+	}
+
+	log.WithFields(log.Fields{
+		"table": tableName,
+	}).Info("Incremental snapshot was requested")
+
+	database.snapshotter.request(tableName)
+
+	return nil
+}
+
+func (database *Database) snapshotChunkSize() int {
+	if database.dbInfo.SnapshotChunkSize <= 0 {
+		return DefaultSnapshotChunkSize
+	}
+
+	return database.dbInfo.SnapshotChunkSize
+}
+
+func (database *Database) emitWatermark(kind string, s *incrementalSnapshot) error {
+	content := fmt.Sprintf("%s:%s:%d", kind, s.id, s.chunk)
+	_, err := database.db.Exec(`SELECT pg_logical_emit_message(false, $1, $2)`, WatermarkPrefix+"."+database.dbInfo.SlotName, content)
+	return err
+}
+
+// readChunk reads rows following the last key of previous chunk
+func (database *Database) readChunk(s *incrementalSnapshot) error {
+
+	chunkSize := database.snapshotChunkSize()
+	r := database.newSnapshotReader(s.table, chunkSize)
+
+	s.order = make([]string, 0, chunkSize)
+	s.rows = make(map[string]snapshotRow, chunkSize)
+
+	var keyErr error
+	_, lastKey, err := r.readKeyset(s.lastKey, func(row map[string]interface{}, text map[string]string) {
+		if err := s.add(row, text); err != nil {
+			keyErr = err
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if keyErr != nil {
+		return keyErr
+	}
+
+	if lastKey != nil {
+		s.lastKey = lastKey
+	}
+
+	s.last = len(s.order) < chunkSize
+
+	return nil
+}
+
+// stepIncrementalSnapshot reads next chunk if the previous one was published already.
+// It returns true while snapshot is running, so that slot is read again without delay, but
+// false while backing off after failed chunk.
+func (database *Database) stepIncrementalSnapshot() bool {
+
+	s := database.snapshotter.current
+	if s == nil {
+		tableName, ok := database.snapshotter.next()
+		if !ok {
+			return false
+		}
+
+		s = &incrementalSnapshot{
+			id:    fmt.Sprintf("%d", time.Now().UnixNano()),
+			table: tableName,
+			keys:  database.GetPrimaryKeys(tableName),
+		}
+
+		database.snapshotter.setCurrent(s)
+
+		log.WithFields(log.Fields{
+			"table": tableName,
+			"id":    s.id,
+		}).Info("Starting incremental snapshot")
+	}
+
+	if database.snapshotter.takeStopped(s.table) {
+		log.WithFields(log.Fields{
+			"table": s.table,
+			"id":    s.id,
+			"chunk": s.chunk,
+		}).Warn("Incremental snapshot was stopped")
+
+		database.snapshotter.setCurrent(nil)
+
+		return true
+	}
+
+	// Waiting for high watermark of current chunk
+	if s.waiting {
+		return true
+	}
+
+	// Backing off after failure, slot is read at normal pace meanwhile
+	if time.Now().Before(s.retryAt) {
+		return false
+	}
+
+	database.waitIfPaused(s.table)
+
+	// Watermarks of chunk which failed are ignored since every attempt has its own number
+	s.chunk++
+	lastKey := s.lastKey
+
+	err := database.emitWatermark(lowWatermark, s)
+	if err == nil {
+		err = database.readChunk(s)
+	}
+	if err == nil {
+		err = database.emitWatermark(highWatermark, s)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": s.table,
+			"chunk": s.chunk,
+		}).Error("Failed to read chunk of incremental snapshot: ", err)
+
+		s.lastKey = lastKey
+		s.rows = nil
+		s.order = nil
+		s.failures++
+
+		if s.failures >= snapshotChunkMaxFailures {
+			log.WithFields(log.Fields{
+				"table":    s.table,
+				"id":       s.id,
+				"failures": s.failures,
+			}).Error("Incremental snapshot was aborted")
+
+			database.snapshotter.setCurrent(nil)
+			return true
+		}
+
+		// Trying the same chunk again later
+		s.retryAt = time.Now().Add(snapshotRetryDelay(s.failures))
+		return false
+	}
+
+	s.failures = 0
+	s.waiting = true
+
+	return true
+}
+
+// snapshotRetryDelay doubles delay on every failure
+func snapshotRetryDelay(failures int) time.Duration {
+
+	delay := time.Second << uint(failures-1)
+	if delay <= 0 || delay > snapshotRetryMaxDelay {
+		return snapshotRetryMaxDelay
+	}
+
+	return delay
+}
+
+func (database *Database) isWatermark(msg *parser.Message) bool {
+	return msg.Prefix == WatermarkPrefix+"."+database.dbInfo.SlotName
+}
+
+func (database *Database) isSnapshotSignal(msg *parser.Message) bool {
+	return msg.Prefix == SnapshotSignalPrefix+"."+database.dbInfo.SlotName
+}
+
+// processWatermark opens window at low watermark and publishes the rest of chunk at high watermark
+func (database *Database) processWatermark(msg *parser.Message) ([]*CDCEvent, error) {
+
+	s := database.snapshotter.current
+	if s == nil || !s.waiting {
+		return nil, EmptyEventTypeErr
+	}
+
+	parts := strings.SplitN(msg.Content, ":", 2)
+	if len(parts) != 2 || parts[1] != fmt.Sprintf("%s:%d", s.id, s.chunk) {
+		return nil, EmptyEventTypeErr
+	}
+
+	if parts[0] == lowWatermark {
+		s.window = true
+		return nil, EmptyEventTypeErr
+	}
+
+	s.window = false
+	s.waiting = false
+
+	events := make([]*CDCEvent, 0, len(s.rows))
+	for _, key := range s.order {
+		row, ok := s.rows[key]
+		if !ok {
+			continue
+		}
+
+		e := database.processSnapshotEvent(s.table, row.data, row.text)
+		e.LastLSN = fmt.Sprintf("incremental-%s-%s", s.id, key)
+		events = append(events, e)
+	}
+
+	log.WithFields(log.Fields{
+		"table":   s.table,
+		"chunk":   s.chunk,
+		"rows":    len(s.order),
+		"changed": len(s.order) - len(events),
+	}).Debug("Chunk of incremental snapshot was read")
+
+	s.rows = nil
+	s.order = nil
+
+	if s.last {
+		log.WithFields(log.Fields{
+			"table": s.table,
+			"id":    s.id,
+		}).Info("Incremental snapshot completed")
+
+		database.snapshotter.setCurrent(nil)
+	}
+
+	if len(events) == 0 {
+		return nil, EmptyEventTypeErr
+	}
+
+	return events, nil
+}
+
+// dropChangedRow removes row from chunk if it was changed within window
+func (database *Database) dropChangedRow(e *CDCEvent) {
+
+	s := database.snapshotter.current
+	if s == nil || !s.window || s.table != e.Table {
+		return
+	}
+
+	key, err := EncodePrimaryKey(s.keys, e.After, e.TextData)
+	if err != nil {
+		return
+	}
+
+	delete(s.rows, key)
+}
+
+func (database *Database) processControlMessage(msg *parser.Message) ([]*CDCEvent, error) {
+
+	if database.isWatermark(msg) {
+		return database.processWatermark(msg)
+	}
+
+	// Nothing to read without database, e.g. replaying capture file
+	if database.db != nil {
+		err := database.RequestIncrementalSnapshot(msg.Content)
+		if err != nil {
+			log.Error("Ignored snapshot signal: ", err)
+		}
+	}
+
+	return nil, EmptyEventTypeErr
+}
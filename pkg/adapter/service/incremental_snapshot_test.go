@@ -0,0 +1,151 @@
+package adapter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalSnapshotWatermark(t *testing.T) {
+
+	database := NewDatabase()
+	database.dbInfo.SlotName = "regression_slot"
+	database.tables = map[string]SourceTable{
+		"public.users": SourceTable{},
+	}
+
+	// Chunk was read between watermarks
+	database.snapshotter.current = &incrementalSnapshot{
+		id:      "1",
+		table:   "public.users",
+		keys:    []string{"id"},
+		chunk:   1,
+		waiting: true,
+		last:    true,
+		order:   []string{`["1"]`, `["2"]`},
+		rows: map[string]snapshotRow{
+			`["1"]`: snapshotRow{data: map[string]interface{}{"id": int64(1), "name": "fred"}},
+			`["2"]`: snapshotRow{data: map[string]interface{}{"id": int64(2), "name": "wilma"}},
+		},
+	}
+
+	row := func(data string) map[string]interface{} {
+		return map[string]interface{}{
+			"lsn":  []byte("0/16B3748"),
+			"xid":  []byte("580"),
+			"data": data,
+		}
+	}
+
+	watermark := func(content string) map[string]interface{} {
+		return row(fmt.Sprintf("message: transactional: 0 prefix: gravity_watermark.regression_slot, sz: %d content:%s", len(content), content))
+	}
+
+	// Changes before low watermark don't affect chunk
+	_, err := database.processEvent(row("table public.users: UPDATE: id[integer]:1 name[text]:'barney'"))
+	assert.Nil(t, err)
+
+	_, err = database.processEvent(watermark("low:1:1"))
+	assert.Equal(t, EmptyEventTypeErr, err)
+
+	// Row changed within window is newer than the one in chunk
+	_, err = database.processEvent(row("table public.users: UPDATE: id[integer]:2 name[text]:'betty'"))
+	assert.Nil(t, err)
+
+	events, err := database.processEvent(watermark("high:1:1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, SnapshotOperation, events[0].Operation)
+	assert.Equal(t, "fred", events[0].After["name"])
+	assert.Equal(t, `incremental-1-["1"]`, events[0].LastLSN)
+
+	// Snapshot is completed with the last chunk
+	assert.Nil(t, database.snapshotter.current)
+}
+
+func TestIncrementalSnapshotTypedKeys(t *testing.T) {
+
+	database := NewDatabase()
+	database.dbInfo.SlotName = "regression_slot"
+	database.tables = map[string]SourceTable{
+		"public.prices": SourceTable{},
+	}
+	database.tableInfo["public.prices"] = tableInfo{
+		primaryKeys: []string{"amount", "valid_from"},
+	}
+
+	s := &incrementalSnapshot{
+		id:      "1",
+		table:   "public.prices",
+		keys:    []string{"amount", "valid_from"},
+		chunk:   1,
+		waiting: true,
+		last:    true,
+		rows:    make(map[string]snapshotRow),
+	}
+	database.snapshotter.current = s
+
+	// Rows as lib/pq scans them for the chunk query, with key columns cast to text
+	r := database.newSnapshotReader("public.prices", 10)
+	for _, amount := range []string{"4.10", "5.00"} {
+		row := map[string]interface{}{
+			"amount":                 []byte(amount),
+			"valid_from":             time.Date(2021, 10, 25, 11, 21, 58, 0, time.FixedZone("", 0)),
+			"label":                  "fred",
+			"gravity.key.amount":     amount,
+			"gravity.key.valid_from": "2021-10-25 11:21:58+00",
+		}
+
+		assert.Nil(t, s.add(row, r.takeKeyText(row)))
+		assert.NotContains(t, row, "gravity.key.amount")
+	}
+
+	row := func(data string) map[string]interface{} {
+		return map[string]interface{}{
+			"lsn":  []byte("0/16B3748"),
+			"xid":  []byte("580"),
+			"data": data,
+		}
+	}
+
+	watermark := func(content string) map[string]interface{} {
+		return row(fmt.Sprintf("message: transactional: 0 prefix: gravity_watermark.regression_slot, sz: %d content:%s", len(content), content))
+	}
+
+	_, err := database.processEvent(watermark("low:1:1"))
+	assert.Equal(t, EmptyEventTypeErr, err)
+
+	// Parser gives float and raw string, which have to match keys of the chunk
+	changes, err := database.processEvent(row("table public.prices: UPDATE: amount[numeric]:4.10 valid_from[timestamp with time zone]:'2021-10-25 11:21:58+00' label[text]:'barney'"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4.1, changes[0].After["amount"])
+
+	events, err := database.processEvent(watermark("high:1:1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, []byte("5.00"), events[0].After["amount"])
+	assert.Equal(t, `incremental-1-["5.00","2021-10-25 11:21:58+00"]`, events[0].LastLSN)
+}
+
+func TestIncrementalSnapshotBackoff(t *testing.T) {
+
+	assert.Equal(t, time.Second, snapshotRetryDelay(1))
+	assert.Equal(t, 4*time.Second, snapshotRetryDelay(3))
+	assert.Equal(t, snapshotRetryMaxDelay, snapshotRetryDelay(10))
+
+	// Chunk is not read again before delay elapsed
+	database := NewDatabase()
+	database.snapshotter.current = &incrementalSnapshot{
+		id:       "1",
+		table:    "public.users",
+		keys:     []string{"id"},
+		chunk:    3,
+		failures: 2,
+		retryAt:  time.Now().Add(time.Minute),
+	}
+
+	assert.False(t, database.stepIncrementalSnapshot())
+	assert.Equal(t, 3, database.snapshotter.current.chunk)
+}
@@ -0,0 +1,241 @@
+package adapter
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/app"
+	"github.com/nats-io/nats.go"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultStateBucket = "GRAVITY_ADAPTER_POSTGRES"
+)
+
+var (
+	// Leading "=" is reserved for encoded names
+	kvKeyTokenPattern = regexp.MustCompile(`^[-_a-zA-Z0-9.][-_=a-zA-Z0-9.]*$`)
+)
+
+// KVStore keeps state in JetStream key-value bucket, so that adapter doesn't need persistent volume
+type KVStore struct {
+	kv     nats.KeyValue
+	legacy *LocalStore
+}
+
+// kvBucket is what state needs from bucket
+type kvBucket interface {
+	Get(key string) (nats.KeyValueEntry, error)
+	Put(key string, value []byte) (uint64, error)
+}
+
+type kvSourceState struct {
+	name   string
+	kv     kvBucket
+	legacy SourceState
+}
+
+// OpenKVStore opens bucket at store.bucket. Local store at store.migrateFrom is read
+// for tables which don't have state in bucket yet.
+func OpenKVStore(a app.App) (*KVStore, error) {
+
+	viper.SetDefault("store.bucket", DefaultStateBucket)
+	viper.SetDefault("store.replicas", 1)
+	bucket := viper.GetString("store.bucket")
+
+	connector := a.GetAdapterConnector()
+	if connector == nil {
+		return nil, ConnectorRequiredErr
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": bucket,
+	}).Info("Initializing store")
+
+	js := connector.GetJetStream()
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "State of gravity-adapter-postgres",
+			Replicas:    viper.GetInt("store.replicas"),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store := &KVStore{
+		kv: kv,
+	}
+
+	migrateFrom := viper.GetString("store.migrateFrom")
+	if len(migrateFrom) > 0 {
+		legacy, err := OpenLocalStore(migrateFrom)
+		if err != nil {
+			return nil, err
+		}
+
+		store.legacy = legacy
+	}
+
+	return store, nil
+}
+
+func (store *KVStore) SourceState(sourceName string) (SourceState, error) {
+
+	state := &kvSourceState{
+		name: sourceName,
+		kv:   store.kv,
+	}
+
+	if store.legacy != nil {
+		legacy, err := store.legacy.SourceState(sourceName)
+		if err != nil {
+			return nil, err
+		}
+
+		state.legacy = legacy
+	}
+
+	return state, nil
+}
+
+func (store *KVStore) Close() error {
+
+	if store.legacy != nil {
+		return store.legacy.Close()
+	}
+
+	return nil
+}
+
+// kvKeyToken keeps names readable, names with characters which are not allowed in keys are encoded
+func kvKeyToken(name string) string {
+
+	if kvKeyTokenPattern.MatchString(name) {
+		return name
+	}
+
+	return "=" + base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func (state *kvSourceState) tableKey(tableName string) string {
+	return "adapter." + kvKeyToken(state.name) + ".tables." + kvKeyToken(tableName)
+}
+
+func (state *kvSourceState) put(tableName string, tableState *TableState) error {
+
+	data, err := json.Marshal(tableState)
+	if err != nil {
+		return err
+	}
+
+	_, err = state.kv.Put(state.tableKey(tableName), data)
+
+	return err
+}
+
+// migrate copies state from local store, table which has never been loaded is left alone
+func (state *kvSourceState) migrate(tableName string) (*TableState, error) {
+
+	tableState, err := state.legacy.GetTableState(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !tableState.InitialLoaded && tableState.SnapshotEpoch == 0 {
+		return tableState, nil
+	}
+
+	err = state.put(tableName, tableState)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source":        state.name,
+		"table":         tableName,
+		"initialLoaded": tableState.InitialLoaded,
+		"snapshotEpoch": tableState.SnapshotEpoch,
+	}).Info("Migrated table state from local store")
+
+	return tableState, nil
+}
+
+func (state *kvSourceState) GetTableState(tableName string) (*TableState, error) {
+
+	entry, err := state.kv.Get(state.tableKey(tableName))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		if state.legacy != nil {
+			return state.migrate(tableName)
+		}
+
+		return &TableState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tableState TableState
+	err = json.Unmarshal(entry.Value(), &tableState)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tableState, nil
+}
+
+func (state *kvSourceState) SetInitialLoaded(tableName string, snapshotEpoch int64) error {
+
+	tableState, err := state.GetTableState(tableName)
+	if err != nil {
+		return err
+	}
+
+	return state.put(tableName, &TableState{
+		InitialLoaded: true,
+		SnapshotEpoch: snapshotEpoch + 1,
+		Progress:      tableState.Progress,
+	})
+}
+
+func (state *kvSourceState) ResetTable(tableName string) error {
+
+	tableState, err := state.GetTableState(tableName)
+	if err != nil {
+		return err
+	}
+
+	tableState.InitialLoaded = false
+	tableState.SnapshotPosition = ""
+
+	return state.put(tableName, tableState)
+}
+
+func (state *kvSourceState) SetSnapshotPosition(tableName string, position string) error {
+
+	tableState, err := state.GetTableState(tableName)
+	if err != nil {
+		return err
+	}
+
+	tableState.SnapshotPosition = position
+
+	return state.put(tableName, tableState)
+}
+
+func (state *kvSourceState) SetLoadProgress(tableName string, progress *LoadProgress) error {
+
+	tableState, err := state.GetTableState(tableName)
+	if err != nil {
+		return err
+	}
+
+	tableState.Progress = progress
+
+	return state.put(tableName, tableState)
+}
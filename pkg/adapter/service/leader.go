@@ -0,0 +1,201 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/monitor"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultLeaderInterval = 2
+
+	FencingTokenHeader = "Gravity-Fencing-Token"
+)
+
+var (
+	LostLeadershipErr = errors.New("Lost leadership")
+
+	leaderGauge = monitor.NewGauge("gravity_postgres_leader", "Whether this instance is leader of source", "source", "slot")
+)
+
+// SourceLeaderElection lets replicas share a slot, only the one holding advisory lock of slot
+// consumes it. Lock is released by PostgreSQL as soon as session of leader is gone.
+type SourceLeaderElection struct {
+	Interval int `json:"interval"`
+}
+
+func (le *SourceLeaderElection) interval() time.Duration {
+	if le.Interval <= 0 {
+		return DefaultLeaderInterval * time.Second
+	}
+
+	return time.Duration(le.Interval) * time.Second
+}
+
+// leaderElector holds advisory lock on a dedicated connection. Fencing token is a transaction ID
+// taken after lock was acquired, so that a newer leader always has a greater token. Slot is read
+// on the same connection, it can't be consumed once session and lock were gone.
+type leaderElector struct {
+	database *Database
+	config   *SourceLeaderElection
+	source   string
+	mutex    sync.Mutex
+	conn     *sqlx.Conn
+	token    int64
+}
+
+func newLeaderElector(database *Database, sourceName string, config *SourceLeaderElection) *leaderElector {
+	return &leaderElector{
+		database: database,
+		config:   config,
+		source:   sourceName,
+	}
+}
+
+// lockKey is derived from slot name, since slot is what replicas compete for
+func (le *leaderElector) lockKey() string {
+	return "gravity." + le.database.dbInfo.SlotName
+}
+
+// tryAcquire returns true if lock was acquired, connection is kept for holding lock
+func (le *leaderElector) tryAcquire() (bool, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), le.config.interval())
+	defer cancel()
+
+	conn, err := le.database.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	acquired := false
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, le.lockKey()).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+		return false, err
+	}
+
+	token := int64(0)
+	err = conn.QueryRowContext(ctx, `SELECT txid_current()`).Scan(&token)
+	if err != nil {
+		// Closing session releases lock as well
+		conn.Close()
+		return false, err
+	}
+
+	le.conn = conn
+	atomic.StoreInt64(&le.token, token)
+
+	return true, nil
+}
+
+// Campaign blocks until this instance becomes leader
+func (le *leaderElector) Campaign() {
+
+	log.WithFields(log.Fields{
+		"source": le.source,
+		"slot":   le.database.dbInfo.SlotName,
+	}).Info("Waiting for leadership")
+
+	leaderGauge.Set(0, le.source, le.database.dbInfo.SlotName)
+
+	for !le.database.stopping {
+		acquired, err := le.tryAcquire()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"source": le.source,
+			}).Warn("Failed to acquire leadership: ", err)
+		}
+
+		if acquired {
+			log.WithFields(log.Fields{
+				"source": le.source,
+				"slot":   le.database.dbInfo.SlotName,
+				"token":  le.Token(),
+			}).Info("Became leader")
+
+			leaderGauge.Set(1, le.source, le.database.dbInfo.SlotName)
+
+			return
+		}
+
+		time.Sleep(le.config.interval())
+	}
+}
+
+// querySlot reads slot on session which holds lock, connection is used by rows only until
+// release was called.
+func (le *leaderElector) querySlot(sqlStr string) (*sqlx.Rows, func(), error) {
+
+	le.mutex.Lock()
+
+	rows, err := le.conn.QueryxContext(context.Background(), sqlStr)
+	if err != nil {
+		le.mutex.Unlock()
+		return nil, nil, err
+	}
+
+	return rows, func() {
+		rows.Close()
+		le.mutex.Unlock()
+	}, nil
+}
+
+// Watch checks session of lock periodically, fn is called once lock can no longer be guaranteed.
+// Session is checked between reads of slot, so events which were read already belong to leader.
+func (le *leaderElector) Watch(fn func(error)) {
+
+	for !le.database.stopping {
+		time.Sleep(le.config.interval())
+
+		le.mutex.Lock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), le.config.interval())
+		err := le.conn.PingContext(ctx)
+		cancel()
+		if err == nil {
+			le.mutex.Unlock()
+			continue
+		}
+
+		// Another replica might take over once session is gone, stop reading before that
+		le.database.stopping = true
+		le.conn.Close()
+		le.mutex.Unlock()
+
+		leaderGauge.Set(0, le.source, le.database.dbInfo.SlotName)
+
+		fn(err)
+
+		return
+	}
+}
+
+// Token returns fencing token, it is zero if this instance is not leader
+func (le *leaderElector) Token() int64 {
+	return atomic.LoadInt64(&le.token)
+}
+
+func (le *leaderElector) IsLeader() bool {
+	return le.Token() != 0
+}
+
+func validateSourceLeaderElection(path string, le *SourceLeaderElection) []error {
+
+	errs := make([]error, 0)
+
+	if le.Interval < 0 {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, "interval"),
+			Message: "must not be negative",
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,43 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestLeaderFencing(t *testing.T) {
+
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	source := &Source{
+		name:             "a",
+		info:             &SourceInfo{},
+		tables:           map[string]SourceTable{},
+		sinks:            map[string]Sink{"": sink},
+		publishBatchSize: 1000,
+		rateLimiter:      rate.NewLimiter(rate.Inf, 0),
+		leader:           &leaderElector{},
+	}
+
+	// Events which were read by leader carry its token
+	request := &Request{
+		Table: "public.orders",
+		Req: &Packet{
+			EventName: "orderCreated",
+			Payload:   []byte(`{"id":1}`),
+			lastLSN:   "0/16B3748-570",
+		},
+	}
+
+	source.leader.token = 42
+	source.HandleRequest(request)
+	sink.Flush(0)
+
+	var record SinkRecord
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "42", record.Header[FencingTokenHeader])
+}
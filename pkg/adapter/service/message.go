@@ -0,0 +1,87 @@
+package adapter
+
+import (
+	"sort"
+	"strings"
+
+	parser "git.brobridge.com/gravity/gravity-adapter-postgres/pkg/adapter/service/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	MessagePrefixHeader = "Gravity-Message-Prefix"
+)
+
+// SourceMessage maps prefix of pg_logical_emit_message to event
+type SourceMessage struct {
+	Event string `json:"event"`
+
+	// Messages which were emitted outside of transaction are ignored by default
+	NonTransactional bool `json:"nonTransactional"`
+}
+
+func (database *Database) processMessageEvent(msg *parser.Message, lastLSN string) ([]*CDCEvent, error) {
+
+	config, ok := database.dbInfo.Messages[msg.Prefix]
+	if !ok {
+		return nil, EmptyEventTypeErr
+	}
+
+	if !msg.Transactional && !config.NonTransactional {
+		log.WithFields(log.Fields{
+			"prefix": msg.Prefix,
+			"lsn":    lastLSN,
+		}).Debug("Skip non-transactional message")
+		return nil, EmptyEventTypeErr
+	}
+
+	e := cdcEventPool.Get().(*CDCEvent)
+	e.Operation = MessageOperation
+	e.Table = msg.Prefix
+	e.Before = nil
+	e.After = nil
+	e.Payload = []byte(msg.Content)
+	e.Partition = ""
+	e.UnchangedToast = nil
+	e.TextData = nil
+	e.LastLSN = lastLSN
+
+	return []*CDCEvent{e}, nil
+}
+
+func validateSourceMessages(path string, messages map[string]SourceMessage) []error {
+
+	errs := make([]error, 0)
+
+	prefixes := make([]string, 0, len(messages))
+	for prefix, _ := range messages {
+		prefixes = append(prefixes, prefix)
+	}
+
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		prefixPath := configPath(path, prefix)
+
+		if len(prefix) == 0 {
+			errs = append(errs, &ConfigError{
+				Path:    prefixPath,
+				Message: "prefix must not be empty",
+			})
+		} else if strings.HasPrefix(prefix, HeartbeatPrefix) {
+			errs = append(errs, &ConfigError{
+				Path:    prefixPath,
+				Message: "prefix is reserved for heartbeat",
+			})
+		}
+
+		if len(messages[prefix].Event) == 0 {
+			errs = append(errs, &ConfigError{
+				Path:    configPath(prefixPath, "event"),
+				Message: "event name is required",
+			})
+		}
+	}
+
+	return errs
+}
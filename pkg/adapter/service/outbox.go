@@ -0,0 +1,256 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultOutboxIDColumn      = "id"
+	DefaultOutboxEventColumn   = "event_type"
+	DefaultOutboxPayloadColumn = "payload"
+
+	outboxCleanupBatchSize = 1000
+)
+
+var (
+	MissingOutboxColumnErr = errors.New("Outbox column is missing")
+)
+
+// SourceOutbox turns rows inserted into outbox table into events
+type SourceOutbox struct {
+	IDColumn        string `json:"idColumn"`
+	EventColumn     string `json:"eventColumn"`
+	PayloadColumn   string `json:"payloadColumn"`
+	SkipRow         bool   `json:"skipRow"`
+	CleanupInterval int    `json:"cleanupInterval"`
+}
+
+func (outbox *SourceOutbox) idColumn() string {
+	if len(outbox.IDColumn) == 0 {
+		return DefaultOutboxIDColumn
+	}
+
+	return outbox.IDColumn
+}
+
+func (outbox *SourceOutbox) eventColumn() string {
+	if len(outbox.EventColumn) == 0 {
+		return DefaultOutboxEventColumn
+	}
+
+	return outbox.EventColumn
+}
+
+func (outbox *SourceOutbox) payloadColumn() string {
+	if len(outbox.PayloadColumn) == 0 {
+		return DefaultOutboxPayloadColumn
+	}
+
+	return outbox.PayloadColumn
+}
+
+// skips tells whether change is dropped as a whole, once rows are skipped only inserted rows
+// become events, deleting rows by cleanup is expected and has nothing to publish.
+func (outbox *SourceOutbox) skips(operation OperationType) bool {
+	return outbox.SkipRow && operation != InsertOperation && operation != ControlOperation
+}
+
+// outboxRequest builds request from outbox row, event name and payload come from the row itself
+func (outbox *SourceOutbox) request(event *CDCEvent) (*Request, error) {
+
+	id, ok := event.After[outbox.idColumn()]
+	if !ok || id == nil {
+		return nil, fmt.Errorf("%v: %s", MissingOutboxColumnErr, outbox.idColumn())
+	}
+
+	eventName, ok := event.After[outbox.eventColumn()].(string)
+	if !ok || len(eventName) == 0 {
+		return nil, fmt.Errorf("%v: %s", MissingOutboxColumnErr, outbox.eventColumn())
+	}
+
+	// json, jsonb and text are all decoded as string
+	var payload []byte
+	switch v := event.After[outbox.payloadColumn()].(type) {
+	case string:
+		payload = []byte(v)
+	case []byte:
+		payload = v
+	case nil:
+	default:
+		return nil, fmt.Errorf("%v: %s", MissingOutboxColumnErr, outbox.payloadColumn())
+	}
+
+	outboxID := keyValueToString(id)
+
+	request := requestPool.Get().(*Request)
+	request.Time = event.Time
+	request.Table = event.Table
+
+	request.Req.EventName = eventName
+	request.Req.Payload = payload
+	request.Req.PrimaryKeys = nil
+	request.Req.PrimaryKey = ""
+	request.Req.UnchangedColumns = nil
+	request.Req.MessagePrefix = ""
+	request.Req.OutboxID = outboxID
+	request.Req.Partition = event.Partition
+	request.Req.lastLSN = "outbox-" + outboxID
+
+	return request, nil
+}
+
+// outboxCleaner deletes rows which were published already
+type outboxCleaner struct {
+	mutex     sync.Mutex
+	published map[string][]string
+}
+
+func newOutboxCleaner() *outboxCleaner {
+	return &outboxCleaner{
+		published: make(map[string][]string),
+	}
+}
+
+func (cleaner *outboxCleaner) add(tableName string, id string) {
+	cleaner.mutex.Lock()
+	defer cleaner.mutex.Unlock()
+	cleaner.published[tableName] = append(cleaner.published[tableName], id)
+}
+
+func (cleaner *outboxCleaner) take(tableName string) []string {
+	cleaner.mutex.Lock()
+	defer cleaner.mutex.Unlock()
+	ids := cleaner.published[tableName]
+	delete(cleaner.published, tableName)
+	return ids
+}
+
+func (database *Database) deleteOutboxRows(tableName string, idColumn string, ids []string) (int64, error) {
+
+	total := int64(0)
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > outboxCleanupBatchSize {
+			n = outboxCleanupBatchSize
+		}
+
+		// Parameters are untyped, so that PostgreSQL converts them to type of id column
+		placeholders := make([]string, n)
+		args := make([]interface{}, n)
+		for i, id := range ids[:n] {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args[i] = id
+		}
+
+		sqlStr := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", tableName, idColumn, strings.Join(placeholders, ", "))
+		result, err := database.db.Exec(sqlStr, args...)
+		if err != nil {
+			return total, err
+		}
+
+		affected, _ := result.RowsAffected()
+		total += affected
+		ids = ids[n:]
+	}
+
+	return total, nil
+}
+
+// startOutboxCleanup starts cleaning up outbox tables, it returns tables which are cleaned up
+func (source *Source) startOutboxCleanup() []string {
+
+	tables := make([]string, 0)
+	for tableName, table := range source.tables {
+		if table.Outbox == nil || table.Outbox.CleanupInterval == 0 {
+			continue
+		}
+
+		// Rows must be kept if events never reach gravity
+		if !source.sinkOf(tableName).Acknowledges() {
+			log.WithFields(log.Fields{
+				"table": tableName,
+			}).Warn("Outbox cleanup is disabled, sink doesn't acknowledge events")
+			continue
+		}
+
+		tables = append(tables, tableName)
+		go source.cleanupOutbox(tableName, table.Outbox)
+	}
+
+	return tables
+}
+
+// cleanupOutbox deletes rows of outbox table once they were acknowledged
+func (source *Source) cleanupOutbox(tableName string, outbox *SourceOutbox) {
+
+	for !source.stopping {
+		time.Sleep(time.Duration(outbox.CleanupInterval) * time.Second)
+
+		ids := source.outboxCleaner.take(tableName)
+		if len(ids) == 0 {
+			continue
+		}
+
+		// Rows can be deleted only after events were acknowledged
+		err := source.flushSinks(time.Duration(outbox.CleanupInterval) * time.Second)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"table": tableName,
+			}).Warn("Outbox cleanup is postponed: ", err)
+
+			for _, id := range ids {
+				source.outboxCleaner.add(tableName, id)
+			}
+
+			continue
+		}
+
+		deleted, err := source.database.deleteOutboxRows(tableName, outbox.idColumn(), ids)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"table": tableName,
+			}).Error("Failed to clean up outbox: ", err)
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"table":   tableName,
+			"deleted": deleted,
+		}).Debug("Outbox was cleaned up")
+	}
+}
+
+func validateSourceOutbox(path string, outbox *SourceOutbox) []error {
+
+	errs := make([]error, 0)
+	report := func(field string, message string) {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, field),
+			Message: message,
+		})
+	}
+
+	if outbox.CleanupInterval < 0 {
+		report("cleanupInterval", "must not be negative")
+	}
+
+	columns := map[string]string{
+		"idColumn":      outbox.idColumn(),
+		"eventColumn":   outbox.eventColumn(),
+		"payloadColumn": outbox.payloadColumn(),
+	}
+
+	for _, field := range []string{"idColumn", "eventColumn", "payloadColumn"} {
+		if !identifierPattern.MatchString(columns[field]) {
+			report(field, "invalid column name")
+		}
+	}
+
+	return errs
+}
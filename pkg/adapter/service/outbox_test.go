@@ -0,0 +1,97 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestOutboxRequest(t *testing.T) {
+
+	outbox := &SourceOutbox{}
+
+	req, err := outbox.request(&CDCEvent{
+		Operation: InsertOperation,
+		Table:     "public.outbox",
+		After: map[string]interface{}{
+			"id":         int64(42),
+			"event_type": "orderPlaced",
+			"payload":    `{"order":1}`,
+		},
+		LastLSN: "0/16B3748-571",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "orderPlaced", req.Req.EventName)
+	assert.Equal(t, []byte(`{"order":1}`), req.Req.Payload)
+	assert.Equal(t, "42", req.Req.OutboxID)
+	assert.Equal(t, "outbox-42", req.Req.lastLSN)
+
+	// Event name is required
+	_, err = outbox.request(&CDCEvent{
+		Operation: InsertOperation,
+		Table:     "public.outbox",
+		After: map[string]interface{}{
+			"id":      int64(43),
+			"payload": `{}`,
+		},
+	})
+	assert.NotNil(t, err)
+
+	// Cleanup of rows is dropped quietly once rows are skipped
+	assert.False(t, outbox.skips(DeleteOperation))
+	outbox.SkipRow = true
+	assert.True(t, outbox.skips(DeleteOperation))
+	assert.True(t, outbox.skips(UpdateOperation))
+	assert.False(t, outbox.skips(InsertOperation))
+	assert.False(t, outbox.skips(ControlOperation))
+}
+
+func TestOutboxCleaner(t *testing.T) {
+
+	cleaner := newOutboxCleaner()
+	cleaner.add("public.outbox", "1")
+	cleaner.add("public.outbox", "2")
+
+	assert.Equal(t, []string{"1", "2"}, cleaner.take("public.outbox"))
+	assert.Equal(t, 0, len(cleaner.take("public.outbox")))
+}
+
+func TestOutboxCleanupWithWriterSink(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	// Database isn't connected, deleting anything would fail
+	source := &Source{
+		name: "a",
+		info: &SourceInfo{},
+		tables: map[string]SourceTable{
+			"public.outbox": SourceTable{
+				Outbox: &SourceOutbox{CleanupInterval: 1},
+			},
+		},
+		database:         NewDatabase(),
+		sinks:            map[string]Sink{"": NewWriterSink(&buf)},
+		publishBatchSize: 1000,
+		rateLimiter:      rate.NewLimiter(rate.Inf, 0),
+		outboxCleaner:    newOutboxCleaner(),
+	}
+
+	// Nothing reaches gravity, so rows are never deleted
+	assert.Equal(t, 0, len(source.startOutboxCleanup()))
+
+	req, err := source.tables["public.outbox"].Outbox.request(&CDCEvent{
+		Operation: InsertOperation,
+		Table:     "public.outbox",
+		After: map[string]interface{}{
+			"id":         int64(42),
+			"event_type": "orderPlaced",
+			"payload":    `{"order":1}`,
+		},
+	})
+	assert.Nil(t, err)
+
+	source.HandleRequest(req)
+	assert.Equal(t, 0, len(source.outboxCleaner.take("public.outbox")))
+}
@@ -16,19 +16,43 @@ const (
 	NullValue
 )
 
+const (
+	NoTupleData         = "(no-tuple-data)"
+	UnchangedToastDatum = "unchanged-toast-datum"
+)
+
 var (
 	InvalidErr = errors.New("Invalid Syntax")
 )
 
 type Parser struct {
-	Operation string
-	Table     string
-	AfterData map[string]interface{}
+	Operation      string
+	Table          string
+	AfterData      map[string]interface{}
+	UnchangedToast []string
+
+	// Values as PostgreSQL prints them, which is the same as casting columns to text
+	TextData map[string]string
+
+	// TRUNCATE might involve multiple tables
+	Tables          []string
+	Cascade         bool
+	RestartIdentity bool
+
+	// Logical decoding message
+	Message *Message
+}
+
+type Message struct {
+	Transactional bool
+	Prefix        string
+	Content       string
 }
 
 func NewParser() *Parser {
 	return &Parser{
 		AfterData: make(map[string]interface{}),
+		TextData:  make(map[string]string),
 	}
 }
 
@@ -40,16 +64,17 @@ func (p *Parser) unescape(text string) string {
 	return text
 }
 
-func (p *Parser) getValue(str string) (ValueType, string, string, error) {
+// getValue returns value and its text form, which only has quotes of literal undoubled
+func (p *Parser) getValue(str string) (ValueType, string, string, string, error) {
 
 	// Check quote character
 	if str[0] == '\'' {
 		v, cur, err := p.getRawString(str)
 		if err != nil {
-			return NormalValue, "", str, InvalidErr
+			return NormalValue, "", "", str, InvalidErr
 		}
 
-		return NormalValue, p.unescape(v), strings.TrimSpace(str[cur:]), nil
+		return NormalValue, p.unescape(v), strings.ReplaceAll(v, "''", "'"), strings.TrimSpace(str[cur:]), nil
 	}
 
 	left := ""
@@ -61,10 +86,10 @@ func (p *Parser) getValue(str string) (ValueType, string, string, error) {
 	}
 
 	if v == "null" {
-		return NullValue, "", left, nil
+		return NullValue, "", "", left, nil
 	}
 
-	return NormalValue, v, left, nil
+	return NormalValue, v, v, left, nil
 }
 
 func (p *Parser) getRawString(text string) (string, int, error) {
@@ -318,6 +343,15 @@ func (p *Parser) parseField(text string) (string, error) {
 		return "", InvalidErr
 	}
 
+	// TOASTed value which was not touched by UPDATE is not in WAL
+	if strings.HasPrefix(text, UnchangedToastDatum) {
+		rest := text[len(UnchangedToastDatum):]
+		if len(rest) == 0 || rest[0] == ' ' {
+			p.UnchangedToast = append(p.UnchangedToast, fieldName)
+			return strings.TrimSpace(rest), nil
+		}
+	}
+
 	// Check whether array type
 	if strings.Contains(fieldType, "[]") {
 		valueType := fieldType[:len(fieldType)-2]
@@ -337,12 +371,13 @@ func (p *Parser) parseField(text string) (string, error) {
 		}
 
 		p.AfterData[fieldName] = value
+		p.TextData[fieldName] = strings.ReplaceAll(str, "''", "'")
 
 		return text, nil
 	}
 
 	// Extract value
-	vt, v, left, err := p.getValue(text)
+	vt, v, literal, left, err := p.getValue(text)
 	if err != nil {
 		return left, err
 	}
@@ -352,6 +387,8 @@ func (p *Parser) parseField(text string) (string, error) {
 		return left, nil
 	}
 
+	p.TextData[fieldName] = literal
+
 	// Getting value
 	switch fieldType {
 	case "boolean":
@@ -402,6 +439,7 @@ func (p *Parser) parseField(text string) (string, error) {
 		}
 
 		p.AfterData[fieldName] = val
+		p.TextData[fieldName] = "\\x" + hex.EncodeToString(val)
 
 	case "money":
 
@@ -454,6 +492,7 @@ func (p *Parser) parseField(text string) (string, error) {
 		}
 
 		p.AfterData[fieldName] = v[2 : len(v)-1]
+		p.TextData[fieldName] = v[2 : len(v)-1]
 
 	default:
 		p.AfterData[fieldName] = v
@@ -481,8 +520,83 @@ func (p *Parser) parseFields(text string) error {
 	return nil
 }
 
+// parseTruncate parses tables and flags of TRUNCATE:
+// table public.a, public.b: TRUNCATE: restart_seqs cascade
+func (p *Parser) parseTruncate(flags string) error {
+
+	p.Tables = strings.Split(p.Table, ", ")
+	p.Table = p.Tables[0]
+
+	for _, flag := range strings.Fields(flags) {
+		switch flag {
+		case "restart_seqs":
+			p.RestartIdentity = true
+		case "cascade":
+			p.Cascade = true
+		case "(no-flags)":
+		default:
+			return InvalidErr
+		}
+	}
+
+	return nil
+}
+
+// parseMessage parses output of pg_logical_emit_message:
+// message: transactional: 1 prefix: outbox, sz: 5 content:hello
+func (p *Parser) parseMessage(text string) error {
+
+	text = strings.TrimPrefix(text, "message: transactional: ")
+	if len(text) < 2 || text[1] != ' ' {
+		return InvalidErr
+	}
+
+	msg := &Message{
+		Transactional: text[0] == '1',
+	}
+
+	text = text[2:]
+	if !strings.HasPrefix(text, "prefix: ") {
+		return InvalidErr
+	}
+
+	text = text[len("prefix: "):]
+
+	i := strings.Index(text, ", sz: ")
+	if i < 0 {
+		return InvalidErr
+	}
+
+	msg.Prefix = text[:i]
+	text = text[i+len(", sz: "):]
+
+	i = strings.Index(text, " content:")
+	if i < 0 {
+		return InvalidErr
+	}
+
+	size, err := strconv.Atoi(text[:i])
+	if err != nil {
+		return InvalidErr
+	}
+
+	msg.Content = text[i+len(" content:"):]
+	if len(msg.Content) != size {
+		return fmt.Errorf("%v: message size is %d but content has %d bytes", InvalidErr, size, len(msg.Content))
+	}
+
+	p.Operation = "MESSAGE"
+	p.Message = msg
+
+	return nil
+}
+
 func (p *Parser) Parse(source string) error {
 
+	if strings.HasPrefix(source, "message: ") {
+		return p.parseMessage(source)
+	}
+
 	if len(source) < 6 {
 		return InvalidErr
 	}
@@ -518,6 +632,15 @@ func (p *Parser) Parse(source string) error {
 		return InvalidErr
 	}
 
+	if p.Operation == "TRUNCATE" {
+		return p.parseTruncate(text)
+	}
+
+	// No key columns for table without replica identity
+	if text == NoTupleData {
+		return nil
+	}
+
 	// Parsing fields
 	return p.parseFields(text)
 }
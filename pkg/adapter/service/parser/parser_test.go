@@ -374,3 +374,119 @@ func TestParseRangeTypes(t *testing.T) {
 	assert.Equal(t, "[\"2010-01-01 06:30:00+00\",\"2010-01-01 07:30:00+00\")", parser.AfterData["tstzrange1"].(string))
 	assert.Equal(t, "empty", parser.AfterData["daterange1"].(string))
 }
+
+func TestParseDeleteWithoutTupleData(t *testing.T) {
+
+	source := `table public.users: DELETE: (no-tuple-data)`
+
+	parser := NewParser()
+
+	err := parser.Parse(source)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "DELETE", parser.Operation)
+	assert.Equal(t, 0, len(parser.AfterData))
+}
+
+func TestParseUnchangedToast(t *testing.T) {
+
+	source := `table public.documents: UPDATE: id[integer]:1 body[text]:unchanged-toast-datum tags[text[]]:unchanged-toast-datum title[text]:'unchanged-toast-datum'`
+
+	parser := NewParser()
+
+	err := parser.Parse(source)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, int64(1), parser.AfterData["id"])
+	assert.Equal(t, []string{"body", "tags"}, parser.UnchangedToast)
+
+	// Quoted string is real value
+	assert.Equal(t, "unchanged-toast-datum", parser.AfterData["title"])
+
+	_, ok := parser.AfterData["body"]
+	assert.False(t, ok)
+}
+
+func TestParseTruncate(t *testing.T) {
+
+	parser := NewParser()
+
+	err := parser.Parse(`table public.orders, public.order_items: TRUNCATE: restart_seqs cascade`)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "TRUNCATE", parser.Operation)
+	assert.Equal(t, "public.orders", parser.Table)
+	assert.Equal(t, []string{"public.orders", "public.order_items"}, parser.Tables)
+	assert.True(t, parser.Cascade)
+	assert.True(t, parser.RestartIdentity)
+
+	parser = NewParser()
+
+	err = parser.Parse(`table public.orders: TRUNCATE: (no-flags)`)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, []string{"public.orders"}, parser.Tables)
+	assert.False(t, parser.Cascade)
+	assert.False(t, parser.RestartIdentity)
+}
+
+func TestParseMessage(t *testing.T) {
+
+	parser := NewParser()
+
+	err := parser.Parse(`message: transactional: 1 prefix: outbox.order, sz: 25 content:{"id":1,"note":"a, b: c"}`)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "MESSAGE", parser.Operation)
+	assert.True(t, parser.Message.Transactional)
+	assert.Equal(t, "outbox.order", parser.Message.Prefix)
+	assert.Equal(t, `{"id":1,"note":"a, b: c"}`, parser.Message.Content)
+
+	parser = NewParser()
+
+	err = parser.Parse(`message: transactional: 0 prefix: audit, sz: 0 content:`)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.False(t, parser.Message.Transactional)
+	assert.Equal(t, "", parser.Message.Content)
+
+	// Truncated content
+	err = NewParser().Parse(`message: transactional: 0 prefix: audit, sz: 10 content:abc`)
+	assert.NotNil(t, err)
+}
+
+func TestParseTextData(t *testing.T) {
+
+	source := `table public.orders: INSERT: amount[numeric]:4.10 created_at[timestamp with time zone]:'2021-10-25 11:21:58+00' name[text]:'a''b' paid[boolean]:true flags[bit]:B'101' btest[bytea]:'\\x01ff' note[text]:null`
+
+	parser := NewParser()
+
+	err := parser.Parse(source)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Text form is what casting columns to text returns, typed values might lose it
+	assert.Equal(t, 4.1, parser.AfterData["amount"])
+	assert.Equal(t, "4.10", parser.TextData["amount"])
+	assert.Equal(t, "2021-10-25 11:21:58+00", parser.TextData["created_at"])
+	assert.Equal(t, "a'b", parser.TextData["name"])
+	assert.Equal(t, "true", parser.TextData["paid"])
+	assert.Equal(t, "101", parser.TextData["flags"])
+	assert.Equal(t, "\\x01ff", parser.TextData["btest"])
+
+	_, ok := parser.TextData["note"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,116 @@
+package adapter
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PartitionHeader = "Gravity-Partition"
+)
+
+// Ancestors of table from the nearest one, names are formatted in the same way as test_decoding does
+const ancestorsSQL = `WITH RECURSIVE ancestors(relid, depth) AS (
+	SELECT inhparent, 1 FROM pg_inherits WHERE inhrelid = $1::text::regclass
+	UNION ALL
+	SELECT i.inhparent, a.depth + 1 FROM pg_inherits i JOIN ancestors a ON i.inhrelid = a.relid
+)
+SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+FROM ancestors a
+JOIN pg_class c ON c.oid = a.relid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+ORDER BY a.depth`
+
+// partitionResolver maps partitions to tables we watch. Partitions which are created
+// later are resolved when their first change arrives.
+type partitionResolver struct {
+	mutex sync.RWMutex
+	roots map[string]string
+}
+
+func newPartitionResolver() *partitionResolver {
+	return &partitionResolver{
+		roots: make(map[string]string),
+	}
+}
+
+func (r *partitionResolver) get(tableName string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	root, ok := r.roots[tableName]
+	return root, ok
+}
+
+func (r *partitionResolver) set(tableName string, root string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.roots[tableName] = root
+}
+
+func (database *Database) getAncestors(tableName string) ([]string, error) {
+
+	ancestors := make([]string, 0)
+	err := database.db.Select(&ancestors, ancestorsSQL, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return ancestors, nil
+}
+
+// resolveTable returns configured table which the table belongs to, and partition name
+// if the table is a partition of it.
+func (database *Database) resolveTable(tableName string) (string, string) {
+
+	if _, ok := database.tables[tableName]; ok {
+		return tableName, ""
+	}
+
+	if root, ok := database.partitions.get(tableName); ok {
+		if len(root) == 0 {
+			return tableName, ""
+		}
+
+		return root, tableName
+	}
+
+	// Nothing to look up without database, e.g. replaying capture file
+	if database.db == nil {
+		return tableName, ""
+	}
+
+	ancestors, err := database.getAncestors(tableName)
+	if err != nil {
+		// Table might be dropped already, trying again next time
+		log.WithFields(log.Fields{
+			"table": tableName,
+		}).Warn("Failed to resolve partition: ", err)
+		return tableName, ""
+	}
+
+	root := ""
+	for _, ancestor := range ancestors {
+		if _, ok := database.tables[ancestor]; ok {
+			root = ancestor
+			break
+		}
+	}
+
+	database.partitions.set(tableName, root)
+
+	if len(root) > 0 {
+		log.WithFields(log.Fields{
+			"partition": tableName,
+			"table":     root,
+		}).Info("Resolved partition")
+
+		if database.capture != nil {
+			database.capture.WritePartition(tableName, root)
+		}
+
+		return root, tableName
+	}
+
+	return tableName, ""
+}
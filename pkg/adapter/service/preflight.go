@@ -0,0 +1,126 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type PreflightStatus int8
+
+const (
+	PreflightPassed = PreflightStatus(iota)
+	PreflightWarning
+	PreflightFailed
+)
+
+func (status PreflightStatus) String() string {
+	switch status {
+	case PreflightPassed:
+		return "PASS"
+	case PreflightWarning:
+		return "WARN"
+	}
+
+	return "FAIL"
+}
+
+type PreflightCheck struct {
+	Name    string
+	Status  PreflightStatus
+	Message string
+}
+
+// Preflight checks database prerequisites of source
+func (database *Database) Preflight(tables map[string]SourceTable) []PreflightCheck {
+
+	checks := make([]PreflightCheck, 0)
+	report := func(name string, status PreflightStatus, format string, args ...interface{}) {
+		checks = append(checks, PreflightCheck{
+			Name:    name,
+			Status:  status,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Connection
+	err := database.db.Ping()
+	if err != nil {
+		report("connection", PreflightFailed, "%v", err)
+		return checks
+	}
+
+	report("connection", PreflightPassed, "connected")
+
+	// WAL level
+	var walLevel string
+	err = database.db.Get(&walLevel, "SHOW wal_level")
+	if err != nil {
+		report("wal_level", PreflightFailed, "%v", err)
+	} else if walLevel != "logical" {
+		report("wal_level", PreflightFailed, "wal_level is %s, logical is required", walLevel)
+	} else {
+		report("wal_level", PreflightPassed, walLevel)
+	}
+
+	// Replication slots
+	var maxSlots string
+	err = database.db.Get(&maxSlots, "SHOW max_replication_slots")
+	if err != nil {
+		report("max_replication_slots", PreflightFailed, "%v", err)
+	} else if n, _ := strconv.Atoi(maxSlots); n < 1 {
+		report("max_replication_slots", PreflightFailed, "max_replication_slots is %s", maxSlots)
+	} else {
+		report("max_replication_slots", PreflightPassed, maxSlots)
+	}
+
+	// Privilege for logical decoding
+	var replication bool
+	err = database.db.Get(&replication, "SELECT rolsuper OR rolreplication FROM pg_roles WHERE rolname = current_user")
+	if err != nil {
+		report("replication_privilege", PreflightFailed, "%v", err)
+	} else if !replication {
+		report("replication_privilege", PreflightFailed, "current user has no REPLICATION privilege")
+	} else {
+		report("replication_privilege", PreflightPassed, "granted")
+	}
+
+	// Slot
+	status, err := database.GetSlotStatus()
+	if err == SlotNotFoundErr {
+		report("slot", PreflightFailed, "slot %s does not exist", database.dbInfo.SlotName)
+	} else if err != nil {
+		report("slot", PreflightFailed, "%v", err)
+	} else if status["plugin"] != OutputPlugin {
+		report("slot", PreflightFailed, "slot %s uses plugin %v, %s is required", database.dbInfo.SlotName, status["plugin"], OutputPlugin)
+	} else {
+		report("slot", PreflightPassed, "%s (%s)", database.dbInfo.SlotName, OutputPlugin)
+	}
+
+	// Tables
+	for tableName, _ := range tables {
+		name := "table " + tableName
+
+		var readable bool
+		err := database.db.Get(&readable, "SELECT has_table_privilege($1, 'SELECT')", tableName)
+		if err != nil {
+			report(name, PreflightFailed, "%v", err)
+			continue
+		}
+
+		if !readable {
+			report(name, PreflightFailed, "no SELECT privilege")
+			continue
+		}
+
+		keys, err := database.getPrimaryKeys(tableName)
+		if err != nil {
+			report(name, PreflightFailed, "%v", err)
+		} else if len(keys) == 0 {
+			report(name, PreflightWarning, "no primary key or replica identity index")
+		} else {
+			report(name, PreflightPassed, "primary keys: %v", keys)
+		}
+	}
+
+	return checks
+}
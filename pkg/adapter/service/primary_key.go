@@ -0,0 +1,147 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PrimaryKeysHeader     = "Gravity-Primary-Keys"
+	PrimaryKeyValueHeader = "Gravity-Primary-Key-Value"
+)
+
+var (
+	MissingPrimaryKeyErr = errors.New("Missing primary key value")
+)
+
+// Replica identity index takes precedence over primary key because it is
+// what test_decoding emits as the old key for UPDATE and DELETE.
+const primaryKeySQL = `SELECT i.indexrelid, a.attname
+FROM pg_index i
+CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+WHERE i.indrelid = $1::regclass AND (i.indisprimary OR i.indisreplident)
+ORDER BY i.indisreplident DESC, i.indisprimary DESC, i.indexrelid, k.ord`
+
+func (database *Database) getPrimaryKeys(tableName string) ([]string, error) {
+
+	rows, err := database.db.Queryx(primaryKeySQL, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	var indexID int64 = 0
+	for rows.Next() {
+		var id int64
+		var column string
+		err := rows.Scan(&id, &column)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only columns of the first index
+		if indexID != 0 && indexID != id {
+			break
+		}
+
+		indexID = id
+		keys = append(keys, column)
+	}
+
+	return keys, rows.Err()
+}
+
+func (database *Database) LoadPrimaryKeys(tables map[string]SourceTable) error {
+
+	for tableName, _ := range tables {
+		keys, err := database.getPrimaryKeys(tableName)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"table": tableName,
+			}).Error("Failed to load primary keys: ", err)
+			return err
+		}
+
+		if len(keys) == 0 {
+			log.WithFields(log.Fields{
+				"table": tableName,
+			}).Warn("No primary key or replica identity index was found")
+		} else {
+			log.WithFields(log.Fields{
+				"table": tableName,
+				"keys":  keys,
+			}).Info("Loaded primary keys")
+		}
+
+		database.updateTableInfo(tableName, func(info *tableInfo) {
+			info.primaryKeys = keys
+		})
+	}
+
+	return nil
+}
+
+func (database *Database) GetPrimaryKeys(tableName string) []string {
+	return database.getTableInfo(tableName).primaryKeys
+}
+
+// EncodePrimaryKey serializes key values as a JSON array of strings in key
+// column order. Values are taken from text, which is text form of PostgreSQL
+// that test_decoding prints and casting to text returns, so that snapshot rows
+// and decoded changes of the same row produce the same value. Values without
+// text form are formatted from data, numeric and timestamp with time zone of
+// them might not match the other side.
+func EncodePrimaryKey(keys []string, data map[string]interface{}, text map[string]string) (string, error) {
+
+	if len(keys) == 0 {
+		return "", nil
+	}
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		if s, ok := text[key]; ok {
+			values[i] = s
+			continue
+		}
+
+		v, ok := data[key]
+		if !ok || v == nil {
+			return "", MissingPrimaryKeyErr
+		}
+
+		values[i] = keyValueToString(v)
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func keyValueToString(v interface{}) string {
+
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,100 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/adapter/service/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodePrimaryKey(t *testing.T) {
+
+	keys := []string{"id", "name"}
+
+	// Decoded change
+	value, err := EncodePrimaryKey(keys, map[string]interface{}{
+		"id":    int64(1),
+		"name":  "fred",
+		"email": "fred@brobridge.com",
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, `["1","fred"]`, value)
+
+	// Snapshot row of the same record
+	value, err = EncodePrimaryKey(keys, map[string]interface{}{
+		"id":   int64(1),
+		"name": []byte("fred"),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, `["1","fred"]`, value)
+}
+
+func TestEncodePrimaryKeyTypes(t *testing.T) {
+
+	keys := []string{"amount", "createdAt"}
+
+	value, err := EncodePrimaryKey(keys, map[string]interface{}{
+		"amount":    float64(4.1),
+		"createdAt": time.Date(2021, 10, 25, 11, 21, 58, 0, time.UTC),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, `["4.1","2021-10-25T11:21:58Z"]`, value)
+}
+
+func TestEncodePrimaryKeyMissing(t *testing.T) {
+
+	_, err := EncodePrimaryKey([]string{"id"}, map[string]interface{}{
+		"name": "fred",
+	}, nil)
+
+	assert.Equal(t, MissingPrimaryKeyErr, err)
+
+	value, err := EncodePrimaryKey([]string{}, map[string]interface{}{
+		"name": "fred",
+	}, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+}
+
+func TestEncodePrimaryKeyTextForm(t *testing.T) {
+
+	keys := []string{"amount", "createdAt"}
+
+	// Snapshot row which lib/pq scanned, key columns were cast to text as well
+	snapshot, err := EncodePrimaryKey(keys, map[string]interface{}{
+		"amount":    []byte("4.10"),
+		"createdAt": time.Date(2021, 10, 25, 11, 21, 58, 0, time.FixedZone("", 0)),
+	}, map[string]string{
+		"amount":    "4.10",
+		"createdAt": "2021-10-25 11:21:58+00",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Decoded change of the same row
+	p := parser.NewParser()
+	err = p.Parse(`table public.prices: UPDATE: amount[numeric]:4.10 createdAt[timestamp with time zone]:'2021-10-25 11:21:58+00'`)
+	if err != nil {
+		t.Error(err)
+	}
+
+	change, err := EncodePrimaryKey(keys, p.AfterData, p.TextData)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, `["4.10","2021-10-25 11:21:58+00"]`, snapshot)
+	assert.Equal(t, snapshot, change)
+}
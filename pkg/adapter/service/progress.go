@@ -0,0 +1,269 @@
+package adapter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/monitor"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LoadRunning   = "running"
+	LoadCompleted = "completed"
+
+	InitialLoadStartedMarker   = "initialLoadStarted"
+	InitialLoadCompletedMarker = "initialLoadCompleted"
+)
+
+var (
+	initialLoadRows       = monitor.NewGauge("gravity_postgres_initial_load_rows", "Rows which were read by initial load", "source", "table")
+	initialLoadTotalRows  = monitor.NewGauge("gravity_postgres_initial_load_total_rows", "Rows of table, estimated from statistics unless exact count is enabled", "source", "table")
+	initialLoadThroughput = monitor.NewGauge("gravity_postgres_initial_load_rows_per_second", "Rows per second which initial load reads since it was started or resumed", "source", "table")
+	initialLoadETA        = monitor.NewGauge("gravity_postgres_initial_load_eta_seconds", "Estimated seconds until initial load completes, 0 if unknown", "source", "table")
+	initialLoadCompleted  = monitor.NewGauge("gravity_postgres_initial_load_completed", "Whether initial load of table was completed", "source", "table")
+)
+
+// SourceInitialLoadProgress configures how progress of initial load is reported
+type SourceInitialLoadProgress struct {
+	ExactCount bool   `json:"exactCount"`
+	Event      string `json:"event"`
+}
+
+// LoadProgress is progress of initial load of a table, it is kept in state store so that
+// rows which were read before restart are still counted.
+type LoadProgress struct {
+	Status        string     `json:"status"`
+	Rows          int64      `json:"rows"`
+	Total         int64      `json:"total"`
+	ExactTotal    bool       `json:"exactTotal"`
+	RowsPerSecond float64    `json:"rowsPerSecond"`
+	ETASeconds    int64      `json:"etaSeconds"`
+	StartedAt     time.Time  `json:"startedAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+
+	// Throughput is measured within current run only
+	resumedAt   time.Time
+	resumedRows int64
+}
+
+func (p *LoadProgress) update(now time.Time) {
+
+	p.UpdatedAt = now
+
+	p.RowsPerSecond = 0
+	elapsed := now.Sub(p.resumedAt).Seconds()
+	if elapsed > 0 {
+		p.RowsPerSecond = float64(p.Rows-p.resumedRows) / elapsed
+	}
+
+	// Estimated total might be less than rows which were read already
+	p.ETASeconds = 0
+	if p.RowsPerSecond > 0 && p.Total > p.Rows {
+		p.ETASeconds = int64(float64(p.Total-p.Rows) / p.RowsPerSecond)
+	}
+}
+
+// progressTracker keeps progress of tables, it is read by goroutines which serve status
+type progressTracker struct {
+	mutex  sync.RWMutex
+	tables map[string]*LoadProgress
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		tables: make(map[string]*LoadProgress),
+	}
+}
+
+// restore loads progress from state store
+func (t *progressTracker) restore(tableName string, p *LoadProgress) {
+
+	if p == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	restored := *p
+	t.tables[tableName] = &restored
+}
+
+// start begins progress of table, rows which were read before are kept if load is resumed
+func (t *progressTracker) start(tableName string, resumed bool, total int64, exact bool, now time.Time) LoadProgress {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.tables[tableName]
+	if !ok || !resumed || p.Status != LoadRunning {
+		p = &LoadProgress{
+			StartedAt: now,
+		}
+		t.tables[tableName] = p
+	}
+
+	p.Status = LoadRunning
+	p.Total = total
+	p.ExactTotal = exact
+	p.FinishedAt = nil
+	p.resumedAt = now
+	p.resumedRows = p.Rows
+	p.update(now)
+
+	return *p
+}
+
+func (t *progressTracker) add(tableName string, rows int64, now time.Time) LoadProgress {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.tables[tableName]
+	if !ok {
+		p = &LoadProgress{
+			Status:    LoadRunning,
+			StartedAt: now,
+			resumedAt: now,
+		}
+		t.tables[tableName] = p
+	}
+
+	p.Rows += rows
+	p.update(now)
+
+	return *p
+}
+
+func (t *progressTracker) complete(tableName string, now time.Time) LoadProgress {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.tables[tableName]
+	if !ok {
+		p = &LoadProgress{
+			StartedAt: now,
+			resumedAt: now,
+		}
+		t.tables[tableName] = p
+	}
+
+	p.update(now)
+	p.Status = LoadCompleted
+	p.ETASeconds = 0
+	p.FinishedAt = &now
+
+	// Rows are exact once table was read through
+	p.Total = p.Rows
+	p.ExactTotal = true
+
+	return *p
+}
+
+func (t *progressTracker) get(tableName string) (LoadProgress, bool) {
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	p, ok := t.tables[tableName]
+	if !ok {
+		return LoadProgress{}, false
+	}
+
+	return *p, true
+}
+
+// countRows returns total rows of table, it is estimated from statistics unless exact count is enabled
+func (database *Database) countRows(r *snapshotReader, estimated int64) (int64, bool) {
+
+	config := database.dbInfo.InitialLoadProgress
+	if config == nil || !config.ExactCount {
+		return estimated, false
+	}
+
+	var total int64
+	sqlStr := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", r.table, r.where())
+	log.Debug(sqlStr)
+
+	err := database.db.QueryRow(sqlStr).Scan(&total)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": r.table,
+		}).Warn("Failed to count rows, using estimate: ", err)
+		return estimated, false
+	}
+
+	return total, true
+}
+
+func reportProgress(sourceName string, tableName string, p LoadProgress) {
+
+	initialLoadRows.Set(float64(p.Rows), sourceName, tableName)
+	initialLoadTotalRows.Set(float64(p.Total), sourceName, tableName)
+	initialLoadThroughput.Set(p.RowsPerSecond, sourceName, tableName)
+	initialLoadETA.Set(float64(p.ETASeconds), sourceName, tableName)
+
+	if p.Status == LoadCompleted {
+		initialLoadCompleted.Set(1, sourceName, tableName)
+	} else {
+		initialLoadCompleted.Set(0, sourceName, tableName)
+	}
+}
+
+func (database *Database) saveProgress(tableName string, p LoadProgress) {
+
+	if database.source == nil || database.source.state == nil {
+		return
+	}
+
+	err := database.source.state.SetLoadProgress(tableName, &p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": tableName,
+		}).Error("Failed to save initial load progress: ", err)
+	}
+}
+
+// processProgressMarker prepares control event which marks start or finish of initial load
+func (database *Database) processProgressMarker(sourceName string, tableName string, marker string, p LoadProgress) *CDCEvent {
+
+	config := database.dbInfo.InitialLoadProgress
+	if config == nil || len(config.Event) == 0 {
+		return nil
+	}
+
+	after := map[string]interface{}{
+		"type":          marker,
+		"source":        sourceName,
+		"table":         tableName,
+		"rows":          p.Rows,
+		"total":         p.Total,
+		"exactTotal":    p.ExactTotal,
+		"startedAt":     p.StartedAt.UTC(),
+		"snapshotEpoch": database.getTableInfo(tableName).snapshotEpoch,
+	}
+
+	if p.FinishedAt != nil {
+		after["finishedAt"] = p.FinishedAt.UTC()
+	}
+
+	e := cdcEventPool.Get().(*CDCEvent)
+	e.Operation = ControlOperation
+	e.Table = tableName
+	e.Before = nil
+	e.After = after
+	e.Payload = nil
+	e.Partition = ""
+	e.UnchangedToast = nil
+	e.TextData = nil
+
+	// Marker of resumed load is deduplicated with the one which was published before restart,
+	// source and table are prefixed to message ID by HandleRequest already
+	e.LastLSN = fmt.Sprintf("%d-%s", database.getTableInfo(tableName).snapshotEpoch, marker)
+
+	return e
+}
@@ -0,0 +1,95 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressTracker(t *testing.T) {
+
+	tracker := newProgressTracker()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	p := tracker.start("public.orders", false, 1000, false, now)
+	assert.Equal(t, LoadRunning, p.Status)
+	assert.Equal(t, int64(0), p.ETASeconds)
+
+	p = tracker.add("public.orders", 100, now.Add(10*time.Second))
+	assert.Equal(t, int64(100), p.Rows)
+	assert.Equal(t, float64(10), p.RowsPerSecond)
+	assert.Equal(t, int64(90), p.ETASeconds)
+
+	// Throughput is measured since resumed, rows before restart are kept
+	restarted := newProgressTracker()
+	restarted.restore("public.orders", &p)
+	p = restarted.start("public.orders", true, 1000, false, now.Add(time.Hour))
+	assert.Equal(t, int64(100), p.Rows)
+	assert.Equal(t, now, p.StartedAt)
+
+	p = restarted.add("public.orders", 100, now.Add(time.Hour+5*time.Second))
+	assert.Equal(t, int64(200), p.Rows)
+	assert.Equal(t, float64(20), p.RowsPerSecond)
+	assert.Equal(t, int64(40), p.ETASeconds)
+
+	// Estimate which was exceeded leaves ETA unknown
+	p = restarted.add("public.orders", 900, now.Add(time.Hour+10*time.Second))
+	assert.Equal(t, int64(0), p.ETASeconds)
+
+	p = restarted.complete("public.orders", now.Add(time.Hour+10*time.Second))
+	assert.Equal(t, LoadCompleted, p.Status)
+	assert.Equal(t, int64(1100), p.Total)
+	assert.True(t, p.ExactTotal)
+	assert.NotNil(t, p.FinishedAt)
+
+	// Load which was not resumed starts over
+	p = restarted.start("public.orders", false, 1000, true, now.Add(2*time.Hour))
+	assert.Equal(t, int64(0), p.Rows)
+	assert.Nil(t, p.FinishedAt)
+}
+
+func TestProgressMarker(t *testing.T) {
+
+	source := &Source{
+		database: NewDatabase(),
+		info: &SourceInfo{
+			InitialLoadProgress: &SourceInitialLoadProgress{
+				Event: "initialLoadProgress",
+			},
+		},
+		tables: map[string]SourceTable{
+			"public.orders": SourceTable{
+				Columns: []string{"status"},
+				Outbox: &SourceOutbox{
+					SkipRow: true,
+				},
+			},
+		},
+	}
+	source.database.dbInfo.InitialLoadProgress = source.info.InitialLoadProgress
+	source.database.tableInfo["public.orders"] = tableInfo{
+		snapshotEpoch: 2,
+		primaryKeys:   []string{"id"},
+	}
+
+	p := source.database.progress.start("public.orders", false, 10, false, time.Now())
+	e := source.database.processProgressMarker("my_postgres", "public.orders", InitialLoadStartedMarker, p)
+	assert.Equal(t, "2-initialLoadStarted", e.LastLSN)
+
+	requests := source.prepareRequests(e)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "initialLoadProgress", requests[0].Req.EventName)
+	assert.Equal(t, "public.orders", requests[0].Table)
+	assert.Equal(t, "", requests[0].Req.PrimaryKey)
+
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(requests[0].Req.Payload, &payload))
+	assert.Equal(t, InitialLoadStartedMarker, payload["type"])
+	assert.Equal(t, "public.orders", payload["table"])
+	assert.Equal(t, float64(10), payload["total"])
+
+	// Nothing is published without event name
+	source.database.dbInfo.InitialLoadProgress = nil
+	assert.Nil(t, source.database.processProgressMarker("my_postgres", "public.orders", InitialLoadStartedMarker, p))
+}
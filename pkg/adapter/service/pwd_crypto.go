@@ -1,22 +1,34 @@
 package adapter
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	EncryptedSecretPrefix = "aesgcm:"
 )
 
+var (
+	SecretKeyRequiredErr = errors.New("Secret key is required, please set secret.key or secret.keyFile")
+	InvalidSecretKeyErr  = errors.New("Secret key must be base64 encoded 16, 24 or 32 bytes")
+	InvalidCiphertextErr = errors.New("Invalid ciphertext")
+)
+
+// Static key of legacy format, it is kept for decrypting existing secrets only and can be
+// replaced with the key which legacy secrets were encrypted with by ldflags at build time.
 var aesKey = "********************************"
 var key = []byte(aesKey)
 
-func PKCS7Padding(ciphertext []byte, blockSize int) []byte {
-	padding := blockSize - len(ciphertext)%blockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(ciphertext, padtext...)
-}
-
 func PKCS7UnPadding(origData []byte) ([]byte, error) {
 	length := len(origData)
 	unpadding := int(origData[length-1])
@@ -26,10 +38,13 @@ func PKCS7UnPadding(origData []byte) ([]byte, error) {
 	return origData[:(length - unpadding)], nil
 }
 
-// Aes Encrypt
-func AesEncrypt(pwd string) (string, error) {
+// Aes Decryt (legacy format)
+func AesDecrypt(pwd string) (string, error) {
 
-	plaintext := []byte(pwd)
+	ciphertext, err := hex.DecodeString(pwd)
+	if err != nil {
+		return "", err
+	}
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -37,36 +52,130 @@ func AesEncrypt(pwd string) (string, error) {
 	}
 
 	blockSize := block.BlockSize()
-	plaintext = PKCS7Padding(plaintext, blockSize)
-	blockMode := cipher.NewCBCEncrypter(block, key[:blockSize])
-	ciphertext := make([]byte, len(plaintext))
-	blockMode.CryptBlocks(ciphertext, plaintext)
+	if len(ciphertext) < blockSize {
+		return "", errors.New("Ciphertext length is less than the AES block size.")
+	}
+
+	if len(ciphertext)%blockSize != 0 {
+		return "", errors.New("Ciphertext length is not a multiple of the AES block size.")
+	}
 
-	return hex.EncodeToString(ciphertext), nil
+	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
+	plaintext := make([]byte, len(ciphertext))
+	blockMode.CryptBlocks(plaintext, ciphertext)
+	plaintext, err = PKCS7UnPadding(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
 }
 
-// Aes Decryt
-func AesDecrypt(pwd string) (string, error) {
+// GetSecretKey returns key which is supplied at runtime by secret.key or secret.keyFile
+func GetSecretKey() ([]byte, error) {
 
-	ciphertext, err := hex.DecodeString(pwd)
+	encoded := viper.GetString("secret.key")
+	if keyFile := viper.GetString("secret.keyFile"); len(encoded) == 0 && len(keyFile) > 0 {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded = string(data)
+	}
+
+	encoded = strings.TrimSpace(encoded)
+	if len(encoded) == 0 {
+		return nil, SecretKeyRequiredErr
+	}
+
+	secretKey, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, InvalidSecretKeyErr
+	}
+
+	switch len(secretKey) {
+	case 16, 24, 32:
+	default:
+		return nil, InvalidSecretKeyErr
+	}
+
+	return secretKey, nil
+}
+
+func GenerateSecretKey() (string, error) {
+
+	secretKey := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, secretKey)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
+	return base64.StdEncoding.EncodeToString(secretKey), nil
+}
+
+// EncryptSecret encrypts with AES-GCM and random nonce, the result is prefixed with EncryptedSecretPrefix
+func EncryptSecret(plaintext string) (string, error) {
+
+	secretKey, err := GetSecretKey()
 	if err != nil {
 		return "", err
 	}
 
-	blockSize := block.BlockSize()
-	if len(ciphertext) < blockSize {
-		return "", errors.New("Ciphertext length is less than the AES block size.")
+	block, err := aes.NewCipher(secretKey)
+	if err != nil {
+		return "", err
 	}
 
-	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
-	plaintext := make([]byte, len(ciphertext))
-	blockMode.CryptBlocks(plaintext, ciphertext)
-	plaintext, err = PKCS7UnPadding(plaintext)
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return EncryptedSecretPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptSecret decrypts both AES-GCM and legacy format
+func DecryptSecret(value string) (string, error) {
+
+	if !strings.HasPrefix(value, EncryptedSecretPrefix) {
+		return AesDecrypt(value)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value[len(EncryptedSecretPrefix):])
+	if err != nil {
+		return "", InvalidCiphertextErr
+	}
+
+	secretKey, err := GetSecretKey()
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(secretKey)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return "", InvalidCiphertextErr
+	}
+
+	nonce := ciphertext[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,180 @@
+package adapter
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Rate limited tables are checked again at least this often, so that other tables are not delayed
+	schedulerMaxDelay = 10 * time.Millisecond
+)
+
+// tableQueue keeps requests of a table in order, every key belongs to exactly one table
+// so that per-key ordering is preserved no matter how tables are interleaved.
+type tableQueue struct {
+	name     string
+	limiter  *rate.Limiter
+	requests []*Request
+}
+
+type priorityLevel struct {
+	priority int
+	queues   []*tableQueue
+	next     int
+}
+
+// requestScheduler decides which request to publish next. Tables of higher priority go first,
+// tables of the same priority take turns, and tables exceeding their rate limit are skipped.
+type requestScheduler struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	tables map[string]SourceTable
+	queues map[string]*tableQueue
+	levels []*priorityLevel
+}
+
+func newRequestScheduler(tables map[string]SourceTable) *requestScheduler {
+
+	scheduler := &requestScheduler{
+		tables: tables,
+		queues: make(map[string]*tableQueue),
+		levels: make([]*priorityLevel, 0),
+	}
+
+	scheduler.cond = sync.NewCond(&scheduler.mutex)
+
+	return scheduler
+}
+
+func newTableLimiter(rateLimit float64) *rate.Limiter {
+
+	if rateLimit <= 0 {
+		return nil
+	}
+
+	burst := int(rateLimit)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(rateLimit), burst)
+}
+
+func (scheduler *requestScheduler) getQueue(tableName string) *tableQueue {
+
+	if q, ok := scheduler.queues[tableName]; ok {
+		return q
+	}
+
+	// Heartbeat and messages don't belong to tables, so they come with default settings
+	table := scheduler.tables[tableName]
+	q := &tableQueue{
+		name:     tableName,
+		limiter:  newTableLimiter(table.RateLimit),
+		requests: make([]*Request, 0),
+	}
+
+	scheduler.queues[tableName] = q
+
+	var level *priorityLevel
+	for _, l := range scheduler.levels {
+		if l.priority == table.Priority {
+			level = l
+			break
+		}
+	}
+
+	if level == nil {
+		level = &priorityLevel{
+			priority: table.Priority,
+			queues:   make([]*tableQueue, 0),
+		}
+
+		scheduler.levels = append(scheduler.levels, level)
+		sort.SliceStable(scheduler.levels, func(i, j int) bool {
+			return scheduler.levels[i].priority > scheduler.levels[j].priority
+		})
+	}
+
+	level.queues = append(level.queues, q)
+
+	return q
+}
+
+func (scheduler *requestScheduler) push(request *Request) {
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	q := scheduler.getQueue(request.Table)
+	q.requests = append(q.requests, request)
+
+	scheduler.cond.Signal()
+}
+
+// next returns request which can be published now, or how long to wait if every table is rate limited
+func (scheduler *requestScheduler) next(now time.Time) (*Request, time.Duration) {
+
+	delay := time.Duration(0)
+	for _, level := range scheduler.levels {
+		n := len(level.queues)
+		for i := 0; i < n; i++ {
+			idx := (level.next + i) % n
+			q := level.queues[idx]
+			if len(q.requests) == 0 {
+				continue
+			}
+
+			if q.limiter != nil {
+				r := q.limiter.ReserveN(now, 1)
+				if d := r.DelayFrom(now); d > 0 {
+					r.CancelAt(now)
+					if delay == 0 || d < delay {
+						delay = d
+					}
+					continue
+				}
+			}
+
+			request := q.requests[0]
+			q.requests[0] = nil
+			q.requests = q.requests[1:]
+			level.next = idx + 1
+
+			return request, 0
+		}
+	}
+
+	return nil, delay
+}
+
+// pop blocks until there is a request to publish
+func (scheduler *requestScheduler) pop() *Request {
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	for {
+		request, delay := scheduler.next(time.Now())
+		if request != nil {
+			return request
+		}
+
+		if delay == 0 {
+			scheduler.cond.Wait()
+			continue
+		}
+
+		if delay > schedulerMaxDelay {
+			delay = schedulerMaxDelay
+		}
+
+		scheduler.mutex.Unlock()
+		time.Sleep(delay)
+		scheduler.mutex.Lock()
+	}
+}
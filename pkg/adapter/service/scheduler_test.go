@@ -0,0 +1,74 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestSchedulerPriority(t *testing.T) {
+
+	scheduler := newRequestScheduler(map[string]SourceTable{
+		"public.orders": SourceTable{
+			Priority: 10,
+		},
+		"public.logs": SourceTable{},
+	})
+
+	push := func(tableName string, lsn string) {
+		scheduler.push(&Request{
+			Table: tableName,
+			Req: &Packet{
+				lastLSN: lsn,
+			},
+		})
+	}
+
+	push("public.logs", "1")
+	push("public.logs", "2")
+	push("public.orders", "3")
+	push("public.orders", "4")
+
+	order := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		order = append(order, scheduler.pop().Req.lastLSN)
+	}
+
+	// Tables of higher priority go first, but requests of a table keep their order
+	assert.Equal(t, []string{"3", "4", "1", "2"}, order)
+}
+
+func TestRequestSchedulerRateLimit(t *testing.T) {
+
+	scheduler := newRequestScheduler(map[string]SourceTable{
+		"public.orders": SourceTable{
+			Priority:  10,
+			RateLimit: 1,
+		},
+		"public.logs": SourceTable{},
+	})
+
+	for _, tableName := range []string{"public.orders", "public.orders", "public.logs"} {
+		scheduler.push(&Request{
+			Table: tableName,
+			Req:   &Packet{},
+		})
+	}
+
+	now := time.Now()
+
+	req, _ := scheduler.next(now)
+	assert.Equal(t, "public.orders", req.Table)
+
+	// Rate limited table doesn't block the others
+	req, _ = scheduler.next(now)
+	assert.Equal(t, "public.logs", req.Table)
+
+	req, delay := scheduler.next(now)
+	assert.Nil(t, req)
+	assert.True(t, delay > 0)
+
+	req, _ = scheduler.next(now.Add(time.Second))
+	assert.Equal(t, "public.orders", req.Table)
+}
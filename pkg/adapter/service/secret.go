@@ -0,0 +1,208 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	FileSecretProvider = "file"
+	EnvSecretProvider  = "env"
+	ExecSecretProvider = "exec"
+
+	DefaultSecretExecTimeout = 10
+)
+
+var (
+	UnsupportedSecretProviderErr = errors.New("Unsupported secret provider")
+)
+
+type SecretSource struct {
+	Provider string   `json:"provider"`
+	Path     string   `json:"path"`
+	Name     string   `json:"name"`
+	Command  []string `json:"command"`
+	Timeout  int      `json:"timeout"`
+}
+
+type SecretProvider interface {
+	GetSecret() (string, error)
+}
+
+func NewSecretProvider(source *SecretSource) (SecretProvider, error) {
+
+	switch source.Provider {
+	case FileSecretProvider:
+		return &FileSecret{
+			path: source.Path,
+		}, nil
+	case EnvSecretProvider:
+		return &EnvSecret{
+			name: source.Name,
+		}, nil
+	case ExecSecretProvider:
+		timeout := source.Timeout
+		if timeout == 0 {
+			timeout = DefaultSecretExecTimeout
+		}
+
+		return &ExecSecret{
+			command: source.Command,
+			timeout: time.Duration(timeout) * time.Second,
+		}, nil
+	}
+
+	return nil, UnsupportedSecretProviderErr
+}
+
+// FileSecret reads secret from mounted file
+type FileSecret struct {
+	path string
+}
+
+func (secret *FileSecret) GetSecret() (string, error) {
+
+	data, err := ioutil.ReadFile(secret.path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecret reads secret from environment variable, or the file which <NAME>_FILE points to
+type EnvSecret struct {
+	name string
+}
+
+func (secret *EnvSecret) GetSecret() (string, error) {
+
+	if value, ok := os.LookupEnv(secret.name + "_FILE"); ok && len(value) > 0 {
+		fileSecret := &FileSecret{
+			path: value,
+		}
+
+		return fileSecret.GetSecret()
+	}
+
+	value, ok := os.LookupEnv(secret.name)
+	if !ok || len(value) == 0 {
+		return "", fmt.Errorf("Environment variable %s is not set", secret.name)
+	}
+
+	return value, nil
+}
+
+// ExecSecret takes secret from standard output of command
+type ExecSecret struct {
+	command []string
+	timeout time.Duration
+}
+
+func (secret *ExecSecret) GetSecret() (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), secret.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, secret.command[0], secret.command[1:]...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Failed to execute %s: %v", secret.command[0], err)
+	}
+
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// ResolvePassword finds password of source in order:
+// <SOURCE>_PASSWORD_FILE, <SOURCE>_PASSWORD, passwordSecret and password.
+// Value which has EncryptedSecretPrefix will be decrypted.
+func ResolvePassword(name string, info *SourceInfo) error {
+
+	envName := fmt.Sprintf("%s_PASSWORD", strings.ToUpper(name))
+
+	var provider SecretProvider
+	if path := os.Getenv(envName + "_FILE"); len(path) > 0 {
+		provider = &FileSecret{
+			path: path,
+		}
+	} else if value := os.Getenv(envName); len(value) > 0 {
+
+		// Password in environment variable is always encrypted
+		pwd, err := DecryptSecret(value)
+		if err != nil {
+			return err
+		}
+
+		info.Password = pwd
+
+		return nil
+	} else if info.PasswordSecret != nil {
+		p, err := NewSecretProvider(info.PasswordSecret)
+		if err != nil {
+			return err
+		}
+
+		provider = p
+	}
+
+	if provider != nil {
+		value, err := provider.GetSecret()
+		if err != nil {
+			return err
+		}
+
+		info.Password = value
+	}
+
+	if strings.HasPrefix(info.Password, EncryptedSecretPrefix) {
+		pwd, err := DecryptSecret(info.Password)
+		if err != nil {
+			return err
+		}
+
+		info.Password = pwd
+	}
+
+	return nil
+}
+
+func validateSecretSource(path string, source *SecretSource) []error {
+
+	errs := make([]error, 0)
+	report := func(field string, message string) {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, field),
+			Message: message,
+		})
+	}
+
+	switch source.Provider {
+	case FileSecretProvider:
+		if len(source.Path) == 0 {
+			report("path", "required")
+		}
+	case EnvSecretProvider:
+		if len(source.Name) == 0 {
+			report("name", "required")
+		}
+	case ExecSecretProvider:
+		if len(source.Command) == 0 {
+			report("command", "required")
+		}
+
+		if source.Timeout < 0 {
+			report("timeout", "must not be negative")
+		}
+	default:
+		report("provider", "must be one of file, env and exec")
+	}
+
+	return errs
+}
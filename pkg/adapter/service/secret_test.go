@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptSecret(t *testing.T) {
+
+	secretKey, err := GenerateSecretKey()
+	if err != nil {
+		t.Error(err)
+	}
+
+	viper.Set("secret.key", secretKey)
+	defer viper.Set("secret.key", "")
+
+	ciphertext, err := EncryptSecret("1qaz@WSX")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Random nonce
+	another, _ := EncryptSecret("1qaz@WSX")
+	assert.NotEqual(t, ciphertext, another)
+
+	plaintext, err := DecryptSecret(ciphertext)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "1qaz@WSX", plaintext)
+}
+
+func TestDecryptLegacySecret(t *testing.T) {
+
+	ciphertext := legacyEncrypt("1qaz@WSX")
+
+	plaintext, err := DecryptSecret(ciphertext)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "1qaz@WSX", plaintext)
+}
+
+func TestEncryptSecretWithoutKey(t *testing.T) {
+
+	_, err := EncryptSecret("1qaz@WSX")
+
+	assert.Equal(t, SecretKeyRequiredErr, err)
+}
+
+func TestResolvePasswordFromFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	ioutil.WriteFile(path, []byte("1qaz@WSX\n"), 0600)
+
+	info := &SourceInfo{
+		Password: "plaintext",
+		PasswordSecret: &SecretSource{
+			Provider: FileSecretProvider,
+			Path:     path,
+		},
+	}
+
+	err = ResolvePassword("my_postgres", info)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "1qaz@WSX", info.Password)
+
+	// <SOURCE>_PASSWORD_FILE takes precedence
+	ioutil.WriteFile(path+"2", []byte("2wsx#EDC"), 0600)
+	os.Setenv("MY_POSTGRES_PASSWORD_FILE", path+"2")
+	defer os.Unsetenv("MY_POSTGRES_PASSWORD_FILE")
+
+	err = ResolvePassword("my_postgres", info)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "2wsx#EDC", info.Password)
+}
+
+func TestResolvePasswordFromCommand(t *testing.T) {
+
+	info := &SourceInfo{
+		PasswordSecret: &SecretSource{
+			Provider: ExecSecretProvider,
+			Command:  []string{"echo", "1qaz@WSX"},
+		},
+	}
+
+	err := ResolvePassword("my_postgres", info)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "1qaz@WSX", info.Password)
+}
+
+// legacyEncrypt encrypts like legacy pwd_encrypt did, adapter only decrypts this format
+func legacyEncrypt(pwd string) string {
+
+	block, _ := aes.NewCipher(key)
+	blockSize := block.BlockSize()
+
+	padding := blockSize - len(pwd)%blockSize
+	plaintext := append([]byte(pwd), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, key[:blockSize]).CryptBlocks(ciphertext, plaintext)
+
+	return hex.EncodeToString(ciphertext)
+}
@@ -0,0 +1,316 @@
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/gravity"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/nats-io/nats.go"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	GravitySinkType = "gravity"
+	FileSinkType    = "file"
+	StdoutSinkType  = "stdout"
+
+	Base64PayloadEncoding = "base64"
+
+	DefaultAckTimeout = 30 * time.Second
+)
+
+var (
+	UnsupportedSinkErr   = errors.New("Unsupported sink type")
+	SinkPathRequiredErr  = errors.New("sink.path is required by file sink")
+	ConnectorRequiredErr = errors.New("Gravity connector is not initialized")
+)
+
+// Sink is where events go
+type Sink interface {
+	// PublishAsync sends event without waiting for acknowledgement
+	PublishAsync(eventName string, payload []byte, meta map[string]string) error
+
+	// WaitForAcks blocks until events which were published were acknowledged
+	WaitForAcks() error
+
+	// Flush makes sure everything was delivered before timeout
+	Flush(timeout time.Duration) error
+
+	// Acknowledges tells whether delivered events were stored by gravity, rows of outbox table
+	// are deleted only if they were
+	Acknowledges() bool
+
+	Close() error
+}
+
+// SinkType returns sink type from configuration
+func SinkType() string {
+	viper.SetDefault("sink.type", GravitySinkType)
+	return viper.GetString("sink.type")
+}
+
+// openSharedSink opens sink which is shared by all sources, it returns nil for gravity sink
+// because acknowledgements are tracked by each source.
+func openSharedSink() (Sink, error) {
+
+	switch SinkType() {
+	case GravitySinkType:
+		return nil, nil
+	case StdoutSinkType:
+		return NewWriterSink(os.Stdout), nil
+	case FileSinkType:
+		path := viper.GetString("sink.path")
+		if len(path) == 0 {
+			return nil, SinkPathRequiredErr
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewWriterSink(f), nil
+	}
+
+	return nil, fmt.Errorf("%v: %s", UnsupportedSinkErr, SinkType())
+}
+
+// GravitySink publishes events to gravity with JetStream
+type GravitySink struct {
+	connector  *gravity.AdapterConnector
+	ackFutures []nats.PubAckFuture
+}
+
+func NewGravitySink(connector *gravity.AdapterConnector, batchSize uint64) (*GravitySink, error) {
+
+	if connector == nil {
+		return nil, ConnectorRequiredErr
+	}
+
+	return &GravitySink{
+		connector:  connector,
+		ackFutures: make([]nats.PubAckFuture, 0, batchSize),
+	}, nil
+}
+
+func (sink *GravitySink) PublishAsync(eventName string, payload []byte, meta map[string]string) error {
+
+	future, err := sink.connector.PublishAsync(eventName, payload, meta)
+	if err != nil {
+		return err
+	}
+
+	sink.ackFutures = append(sink.ackFutures, future)
+
+	return nil
+}
+
+func (sink *GravitySink) WaitForAcks() error {
+
+	lastFuture := 0
+	isError := false
+RETRY:
+	for i, future := range sink.ackFutures {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultAckTimeout)
+		select {
+		case <-future.Ok():
+		case <-ctx.Done():
+			log.Warnf("Failed to publish message, retry ...")
+			lastFuture = i
+			isError = true
+			cancel()
+			break RETRY
+		}
+		cancel()
+	}
+
+	if isError {
+		js := sink.connector.GetJetStream()
+		js.CleanupPublisher()
+		log.Trace("start retry ...  ", len(sink.ackFutures[lastFuture:]))
+		for _, future := range sink.ackFutures[lastFuture:] {
+			// send msg with Sync mode
+			for {
+				_, err := js.PublishMsg(future.Msg())
+				if err != nil {
+					log.Warn(err, ", retry ...")
+					time.Sleep(time.Second)
+					continue
+				}
+				break
+			}
+		}
+		log.Trace("retry done")
+	}
+
+	sink.ackFutures = sink.ackFutures[:0]
+
+	return nil
+}
+
+func (sink *GravitySink) Flush(timeout time.Duration) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	select {
+	case <-sink.connector.PublishAsyncComplete():
+	case <-ctx.Done():
+		return fmt.Errorf("Timeout waiting for acknowledgements. AsyncPending: %d", sink.connector.GetJetStream().PublishAsyncPending())
+	}
+
+	return nil
+}
+
+func (sink *GravitySink) Acknowledges() bool {
+	return true
+}
+
+func (sink *GravitySink) Close() error {
+	return nil
+}
+
+// SinkRecord is a line written by WriterSink, payload which is not JSON, such as content of
+// logical decoding message, is written as base64 string with encoding.
+type SinkRecord struct {
+	Time      time.Time           `json:"time"`
+	EventName string              `json:"event"`
+	Header    map[string]string   `json:"header"`
+	Payload   jsoniter.RawMessage `json:"payload"`
+	Encoding  string              `json:"encoding,omitempty"`
+}
+
+// Data returns payload as it was published
+func (record *SinkRecord) Data() ([]byte, error) {
+
+	if record.Encoding != Base64PayloadEncoding {
+		return record.Payload, nil
+	}
+
+	var encoded string
+	err := json.Unmarshal(record.Payload, &encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// ReadSinkRecords calls fn with every record which WriterSink wrote
+func ReadSinkRecords(r io.Reader, fn func(*SinkRecord) error) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record SinkRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		err = fn(&record)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// WriterSink writes events as newline-delimited JSON, it is used for local runs
+type WriterSink struct {
+	mutex  sync.Mutex
+	writer *bufio.Writer
+	closer io.Closer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+
+	sink := &WriterSink{
+		writer: bufio.NewWriter(w),
+	}
+
+	// Never close standard output
+	if c, ok := w.(io.Closer); ok && w != os.Stdout {
+		sink.closer = c
+	}
+
+	return sink
+}
+
+func (sink *WriterSink) PublishAsync(eventName string, payload []byte, meta map[string]string) error {
+
+	record := SinkRecord{
+		Time:      time.Now(),
+		EventName: eventName,
+		Header:    meta,
+		Payload:   jsoniter.RawMessage(payload),
+	}
+
+	if !json.Valid(payload) {
+		encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(payload))
+		record.Payload = jsoniter.RawMessage(encoded)
+		record.Encoding = Base64PayloadEncoding
+	}
+
+	data, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	_, err = sink.writer.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return sink.writer.WriteByte('\n')
+}
+
+func (sink *WriterSink) WaitForAcks() error {
+	return sink.Flush(0)
+}
+
+func (sink *WriterSink) Flush(timeout time.Duration) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.writer.Flush()
+}
+
+// Acknowledges is false since nothing reaches gravity, e.g. local dry run
+func (sink *WriterSink) Acknowledges() bool {
+	return false
+}
+
+func (sink *WriterSink) Close() error {
+
+	err := sink.Flush(0)
+	if err != nil {
+		return err
+	}
+
+	if sink.closer != nil {
+		return sink.closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterSink(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	sink := NewWriterSink(buf)
+
+	err := sink.PublishAsync("accountCreated", []byte(`{"id":1}`), map[string]string{
+		"Nats-Msg-Id": "source-public.account-0/1",
+	})
+	assert.Nil(t, err)
+
+	err = sink.PublishAsync("accountDeleted", []byte(`{"id":2}`), map[string]string{})
+	assert.Nil(t, err)
+
+	// Nothing was written before flush
+	assert.Equal(t, 0, buf.Len())
+
+	assert.Nil(t, sink.Flush(0))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+
+	var record SinkRecord
+	err = json.Unmarshal([]byte(lines[0]), &record)
+	assert.Nil(t, err)
+	assert.Equal(t, "accountCreated", record.EventName)
+	assert.Equal(t, "source-public.account-0/1", record.Header["Nats-Msg-Id"])
+	assert.Equal(t, `{"id":1}`, string(record.Payload))
+}
+
+func TestWriterSinkRawPayload(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	sink := NewWriterSink(buf)
+
+	assert.Nil(t, sink.PublishAsync("auditLogged", []byte("hello world"), map[string]string{}))
+	assert.Nil(t, sink.PublishAsync("accountCreated", []byte(`{"id":1}`), map[string]string{}))
+	assert.Nil(t, sink.Flush(0))
+
+	payloads := make([]string, 0)
+	encodings := make([]string, 0)
+	err := ReadSinkRecords(buf, func(record *SinkRecord) error {
+		data, err := record.Data()
+		payloads = append(payloads, string(data))
+		encodings = append(encodings, record.Encoding)
+		return err
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"hello world", `{"id":1}`}, payloads)
+	assert.Equal(t, []string{Base64PayloadEncoding, ""}, encodings)
+}
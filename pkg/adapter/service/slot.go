@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const (
+	OutputPlugin = "test_decoding"
+)
+
+var (
+	SlotNotFoundErr = errors.New("Replication slot not found")
+)
+
+func (database *Database) GetSlotStatus() (map[string]interface{}, error) {
+
+	// Columns of pg_replication_slots vary between versions
+	row := database.db.QueryRowx(`SELECT * FROM pg_replication_slots WHERE slot_name = $1`, database.dbInfo.SlotName)
+
+	status := make(map[string]interface{})
+	err := row.MapScan(status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, SlotNotFoundErr
+		}
+
+		return nil, err
+	}
+
+	for k, v := range status {
+		if b, ok := v.([]byte); ok {
+			status[k] = string(b)
+		}
+	}
+
+	return status, nil
+}
+
+func (database *Database) CreateSlot() error {
+
+	sqlStr := fmt.Sprintf(`SELECT * FROM pg_create_logical_replication_slot('%s', '%s')`,
+		database.dbInfo.SlotName,
+		OutputPlugin,
+	)
+
+	_, err := database.db.Exec(sqlStr)
+	return err
+}
+
+func (database *Database) DropSlot() error {
+
+	sqlStr := fmt.Sprintf(`SELECT pg_drop_replication_slot('%s')`,
+		database.dbInfo.SlotName,
+	)
+
+	_, err := database.db.Exec(sqlStr)
+	return err
+}
+
+// AdvanceSlot discards changes up to specific LSN, or all pending changes if lsn is empty.
+// Changes are skipped without being decoded, it returns LSN which slot was advanced to.
+func (database *Database) AdvanceSlot(lsn string) (string, error) {
+
+	var upto interface{}
+	if len(lsn) > 0 {
+		upto = lsn
+	}
+
+	var end string
+	err := database.db.Get(&end, `SELECT end_lsn::text FROM pg_replication_slot_advance($1, COALESCE($2::pg_lsn, pg_current_wal_lsn()))`, database.dbInfo.SlotName, upto)
+	if err != nil {
+		return "", err
+	}
+
+	return end, nil
+}
@@ -0,0 +1,304 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/monitor"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSlotMonitorInterval = 30
+
+	PauseInitialLoadAction = "pauseInitialLoad"
+	FailReadinessAction    = "failReadiness"
+)
+
+type SlotLevel int8
+
+const (
+	SlotNormal = SlotLevel(iota)
+	SlotWarning
+	SlotCritical
+)
+
+func (level SlotLevel) String() string {
+	switch level {
+	case SlotWarning:
+		return "warning"
+	case SlotCritical:
+		return "critical"
+	}
+
+	return "normal"
+}
+
+var (
+	slotRetainedBytes = monitor.NewGauge("gravity_postgres_slot_retained_bytes", "WAL retained by replication slot", "source", "slot")
+	slotLagBytes      = monitor.NewGauge("gravity_postgres_slot_lag_bytes", "Distance between current WAL and confirmed flush position of slot", "source", "slot")
+	slotSafeWALSize   = monitor.NewGauge("gravity_postgres_slot_safe_wal_size_bytes", "WAL which can be written before slot is in danger of getting lost", "source", "slot")
+	slotActive        = monitor.NewGauge("gravity_postgres_slot_active", "Whether replication slot is in use", "source", "slot")
+	slotWALStatus     = monitor.NewGauge("gravity_postgres_slot_wal_status", "WAL status of replication slot", "source", "slot", "status")
+	slotLevel         = monitor.NewGauge("gravity_postgres_slot_level", "Level of slot status, 0 is normal, 1 is warning and 2 is critical", "source", "slot")
+)
+
+var walStatuses = []string{"reserved", "extended", "unreserved", "lost"}
+
+// SlotMonitor defines thresholds of slot monitoring
+type SlotMonitor struct {
+	Interval      int    `json:"interval"`
+	WarningBytes  int64  `json:"warningBytes"`
+	CriticalBytes int64  `json:"criticalBytes"`
+	Action        string `json:"action"`
+}
+
+type SlotSample struct {
+	Active        bool
+	WALStatus     string
+	RetainedBytes int64
+	LagBytes      int64
+	SafeWALSize   *int64
+}
+
+// Level evaluates sample with thresholds
+func (m *SlotMonitor) Level(sample *SlotSample) (SlotLevel, string) {
+
+	switch sample.WALStatus {
+	case "lost", "unreserved":
+		return SlotCritical, fmt.Sprintf("wal_status is %s", sample.WALStatus)
+	}
+
+	if m.CriticalBytes > 0 && sample.RetainedBytes >= m.CriticalBytes {
+		return SlotCritical, fmt.Sprintf("%d bytes of WAL retained", sample.RetainedBytes)
+	}
+
+	if sample.WALStatus == "extended" {
+		return SlotWarning, "wal_status is extended"
+	}
+
+	if m.WarningBytes > 0 && sample.RetainedBytes >= m.WarningBytes {
+		return SlotWarning, fmt.Sprintf("%d bytes of WAL retained", sample.RetainedBytes)
+	}
+
+	return SlotNormal, ""
+}
+
+func parseBytes(value interface{}) (int64, bool) {
+
+	if value == nil {
+		return 0, false
+	}
+
+	if n, ok := value.(int64); ok {
+		return n, true
+	}
+
+	f, err := strconv.ParseFloat(columnString(value), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return int64(f), true
+}
+
+// SampleSlot reads status of replication slot
+func (database *Database) SampleSlot() (*SlotSample, error) {
+
+	// wal_status and safe_wal_size are available since PostgreSQL 13
+	row := database.db.QueryRowx(`SELECT *,
+		pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn) AS retained_bytes,
+		pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn) AS lag_bytes
+		FROM pg_replication_slots WHERE slot_name = $1`, database.dbInfo.SlotName)
+
+	status := make(map[string]interface{})
+	err := row.MapScan(status)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := &SlotSample{
+		WALStatus: columnString(status["wal_status"]),
+	}
+
+	if active, ok := status["active"].(bool); ok {
+		sample.Active = active
+	}
+
+	sample.RetainedBytes, _ = parseBytes(status["retained_bytes"])
+	sample.LagBytes, _ = parseBytes(status["lag_bytes"])
+
+	if size, ok := parseBytes(status["safe_wal_size"]); ok {
+		sample.SafeWALSize = &size
+	}
+
+	return sample, nil
+}
+
+func (database *Database) exportSlotSample(sourceName string, sample *SlotSample) {
+
+	slot := database.dbInfo.SlotName
+
+	slotRetainedBytes.Set(float64(sample.RetainedBytes), sourceName, slot)
+	slotLagBytes.Set(float64(sample.LagBytes), sourceName, slot)
+
+	if sample.SafeWALSize != nil {
+		slotSafeWALSize.Set(float64(*sample.SafeWALSize), sourceName, slot)
+	}
+
+	active := 0.0
+	if sample.Active {
+		active = 1
+	}
+
+	slotActive.Set(active, sourceName, slot)
+
+	if len(sample.WALStatus) > 0 {
+		for _, status := range walStatuses {
+			v := 0.0
+			if status == sample.WALStatus {
+				v = 1
+			}
+
+			slotWALStatus.Set(v, sourceName, slot, status)
+		}
+	}
+}
+
+// MonitorSlot samples slot periodically until database is stopping
+func (database *Database) MonitorSlot(sourceName string, m *SlotMonitor) {
+
+	interval := m.Interval
+	if interval == 0 {
+		interval = DefaultSlotMonitorInterval
+	}
+
+	component := "slot " + sourceName
+	last := SlotNormal
+
+	for !database.stopping {
+
+		sample, err := database.SampleSlot()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"source": sourceName,
+				"slot":   database.dbInfo.SlotName,
+			}).Error("Failed to sample slot: ", err)
+
+			time.Sleep(time.Duration(interval) * time.Second)
+			continue
+		}
+
+		database.exportSlotSample(sourceName, sample)
+
+		level, reason := m.Level(sample)
+		slotLevel.Set(float64(level), sourceName, database.dbInfo.SlotName)
+
+		fields := log.Fields{
+			"source":   sourceName,
+			"slot":     database.dbInfo.SlotName,
+			"retained": sample.RetainedBytes,
+			"lag":      sample.LagBytes,
+		}
+
+		switch level {
+		case SlotCritical:
+			log.WithFields(fields).Error("Replication slot is critical: ", reason)
+		case SlotWarning:
+			log.WithFields(fields).Warn("Replication slot needs attention: ", reason)
+		default:
+			if last != SlotNormal {
+				log.WithFields(fields).Info("Replication slot is back to normal")
+			}
+		}
+
+		// Taking action
+		critical := level == SlotCritical
+		switch m.Action {
+		case PauseInitialLoadAction:
+			if critical {
+				atomic.StoreInt32(&database.paused, 1)
+			} else {
+				atomic.StoreInt32(&database.paused, 0)
+			}
+		case FailReadinessAction:
+			if critical {
+				monitor.SetUnready(component, reason)
+			} else {
+				monitor.SetReady(component)
+			}
+		}
+
+		last = level
+
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+// isPaused tells whether slot is critical or source was paused by control command
+func (database *Database) isPaused() bool {
+	return atomic.LoadInt32(&database.paused) == 1 || atomic.LoadInt32(&database.suspended) == 1
+}
+
+// waitIfPaused blocks initial load while slot is critical
+func (database *Database) waitIfPaused(tableName string) {
+
+	if !database.isPaused() {
+		return
+	}
+
+	reason := "replication slot is critical"
+	if atomic.LoadInt32(&database.suspended) == 1 {
+		reason = "source was paused"
+	}
+
+	log.WithFields(log.Fields{
+		"table":  tableName,
+		"reason": reason,
+	}).Warn("Initial load is paused")
+
+	for database.isPaused() && !database.stopping {
+		time.Sleep(time.Second)
+	}
+
+	log.WithFields(log.Fields{
+		"table": tableName,
+	}).Info("Initial load is resumed")
+}
+
+func validateSlotMonitor(path string, m *SlotMonitor) []error {
+
+	errs := make([]error, 0)
+	report := func(field string, message string) {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, field),
+			Message: message,
+		})
+	}
+
+	if m.Interval < 0 {
+		report("interval", "must not be negative")
+	}
+
+	if m.WarningBytes < 0 {
+		report("warningBytes", "must not be negative")
+	}
+
+	if m.CriticalBytes < 0 {
+		report("criticalBytes", "must not be negative")
+	}
+
+	if m.WarningBytes > 0 && m.CriticalBytes > 0 && m.WarningBytes > m.CriticalBytes {
+		report("warningBytes", "must not be greater than criticalBytes")
+	}
+
+	switch m.Action {
+	case "", PauseInitialLoadAction, FailReadinessAction:
+	default:
+		report("action", "must be one of pauseInitialLoad and failReadiness")
+	}
+
+	return errs
+}
@@ -0,0 +1,32 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlotMonitorLevel(t *testing.T) {
+
+	m := &SlotMonitor{
+		WarningBytes:  1024,
+		CriticalBytes: 4096,
+	}
+
+	level, _ := m.Level(&SlotSample{WALStatus: "reserved", RetainedBytes: 100})
+	assert.Equal(t, SlotNormal, level)
+
+	level, _ = m.Level(&SlotSample{WALStatus: "reserved", RetainedBytes: 2048})
+	assert.Equal(t, SlotWarning, level)
+
+	level, _ = m.Level(&SlotSample{WALStatus: "extended", RetainedBytes: 100})
+	assert.Equal(t, SlotWarning, level)
+
+	level, _ = m.Level(&SlotSample{WALStatus: "reserved", RetainedBytes: 4096})
+	assert.Equal(t, SlotCritical, level)
+
+	// Slot is about to be invalidated regardless of thresholds
+	level, reason := m.Level(&SlotSample{WALStatus: "unreserved"})
+	assert.Equal(t, SlotCritical, level)
+	assert.Equal(t, "wal_status is unreserved", reason)
+}
@@ -0,0 +1,96 @@
+package adapter
+
+import (
+	"sync/atomic"
+	"time"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/monitor"
+)
+
+const (
+	DefaultMaxChangesPerRead = 10000
+	DefaultMaxBytesPerRead   = 64 * 1024 * 1024
+)
+
+var (
+	slotChangesRead = monitor.NewCounter("gravity_postgres_slot_changes_read_total", "Changes read from replication slot", "source")
+	slotBytesRead   = monitor.NewCounter("gravity_postgres_slot_bytes_read_total", "Bytes of decoded changes read from replication slot", "source")
+	pendingEvents   = monitor.NewGauge("gravity_postgres_pending_events", "Events which were read but not published yet", "source")
+)
+
+// readLimit decides how many changes the next read of slot takes. Output of logical
+// decoding can't be limited by size, so bytes per change of previous reads is used to
+// estimate how many changes fit in maxBytes.
+type readLimit struct {
+	maxChanges     int
+	maxBytes       int64
+	bytesPerChange float64
+}
+
+func newReadLimit(maxChanges int, maxBytes int64) *readLimit {
+
+	if maxChanges <= 0 {
+		maxChanges = DefaultMaxChangesPerRead
+	}
+
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytesPerRead
+	}
+
+	return &readLimit{
+		maxChanges: maxChanges,
+		maxBytes:   maxBytes,
+	}
+}
+
+func (r *readLimit) next() int {
+
+	if r.bytesPerChange == 0 {
+		return r.maxChanges
+	}
+
+	n := int(float64(r.maxBytes) / r.bytesPerChange)
+	if n < 1 {
+		return 1
+	}
+
+	if n > r.maxChanges {
+		return r.maxChanges
+	}
+
+	return n
+}
+
+func (r *readLimit) observe(changes int, bytes int64) {
+
+	if changes == 0 {
+		return
+	}
+
+	current := float64(bytes) / float64(changes)
+	if r.bytesPerChange == 0 {
+		r.bytesPerChange = current
+		return
+	}
+
+	// Moving average to smooth out single large transaction
+	r.bytesPerChange = r.bytesPerChange*0.7 + current*0.3
+}
+
+// waitForCapacity blocks until events of previous reads were mostly published
+func (database *Database) waitForCapacity(limit int) {
+
+	if database.source == nil {
+		return
+	}
+
+	for !database.stopping {
+		pending := atomic.LoadInt64(&database.source.pending)
+		pendingEvents.Set(float64(pending), database.source.name)
+		if pending < int64(limit) {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,39 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLimit(t *testing.T) {
+
+	limit := newReadLimit(1000, 100*1024)
+
+	// Taking max changes before knowing size of changes
+	assert.Equal(t, 1000, limit.next())
+
+	// 1KB per change, only 100 changes fit in 100KB
+	limit.observe(1000, 1000*1024)
+	assert.Equal(t, 100, limit.next())
+
+	// Small changes are still capped by count
+	limit = newReadLimit(1000, 100*1024)
+	limit.observe(10, 100)
+	assert.Equal(t, 1000, limit.next())
+
+	// At least one change is taken even if it is larger than max bytes
+	limit = newReadLimit(1000, 1024)
+	limit.observe(1, 1024*1024)
+	assert.Equal(t, 1, limit.next())
+
+	// Empty read doesn't change estimation
+	limit.observe(0, 0)
+	assert.Equal(t, 1, limit.next())
+}
+
+func TestReadLimitDefaults(t *testing.T) {
+	limit := newReadLimit(0, 0)
+	assert.Equal(t, DefaultMaxChangesPerRead, limit.maxChanges)
+	assert.Equal(t, int64(DefaultMaxBytesPerRead), limit.maxBytes)
+}
@@ -0,0 +1,406 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultInitialLoadBatchSize = 100000
+
+	ctidPositionPrefix = "ctid:"
+
+	// Key columns are read in text form as well, aliases are unlikely to be names of columns
+	keyTextPrefix = "gravity.key."
+)
+
+// snapshotReader reads table page by page, every page is a statement of its own so that
+// no transaction is kept open for the whole table. Pages follow primary key, or ranges of
+// blocks for tables without primary key.
+type snapshotReader struct {
+	database *Database
+	table    string
+	keys     []string
+	columns  []string
+	filter   string
+	pageSize int
+}
+
+func (database *Database) newSnapshotReader(tableName string, pageSize int) *snapshotReader {
+
+	config := database.tables[tableName]
+	keys := database.GetPrimaryKeys(tableName)
+
+	// Keys are always read, they are required by paging and message ID
+	var columns []string
+	if len(config.Columns) > 0 {
+		columns = append(columns, keys...)
+		for _, column := range config.Columns {
+			if !containsString(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	return &snapshotReader{
+		database: database,
+		table:    tableName,
+		keys:     keys,
+		columns:  columns,
+		filter:   config.Filter,
+		pageSize: pageSize,
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *snapshotReader) selectList() string {
+
+	columns := make([]string, 0, len(r.columns)+len(r.keys))
+	if len(r.columns) == 0 {
+		columns = append(columns, "*")
+	}
+
+	for _, column := range r.columns {
+		columns = append(columns, pq.QuoteIdentifier(column))
+	}
+
+	// Key in text form matches what test_decoding prints for changes of the same row
+	for _, key := range r.keys {
+		columns = append(columns, fmt.Sprintf("%s::text AS %s", pq.QuoteIdentifier(key), pq.QuoteIdentifier(keyTextPrefix+key)))
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+func (r *snapshotReader) where(conditions ...string) string {
+
+	if len(r.filter) > 0 {
+		conditions = append([]string{"(" + r.filter + ")"}, conditions...)
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// keysetQuery returns statement which reads a page following the last key
+func (r *snapshotReader) keysetQuery(after bool) string {
+
+	columns := make([]string, len(r.keys))
+	params := make([]string, len(r.keys))
+	for i, key := range r.keys {
+		columns[i] = pq.QuoteIdentifier(key)
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	conditions := make([]string, 0, 1)
+	if after {
+		conditions = append(conditions, fmt.Sprintf("(%s) > (%s)", strings.Join(columns, ", "), strings.Join(params, ", ")))
+	}
+
+	return fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d",
+		r.selectList(),
+		r.table,
+		r.where(conditions...),
+		strings.Join(columns, ", "),
+		r.pageSize,
+	)
+}
+
+func (r *snapshotReader) query(sqlStr string, args []interface{}, fn func(map[string]interface{}, map[string]string)) (int, map[string]string, error) {
+
+	log.Debug(sqlStr)
+
+	rows, err := r.database.db.Queryx(sqlStr, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+
+	count := 0
+	var last map[string]string
+	for rows.Next() {
+		row := make(map[string]interface{})
+		err := rows.MapScan(row)
+		if err != nil {
+			return count, last, err
+		}
+
+		text := r.takeKeyText(row)
+
+		count++
+		last = text
+		fn(row, text)
+	}
+
+	return count, last, rows.Err()
+}
+
+// takeKeyText removes key columns in text form from row
+func (r *snapshotReader) takeKeyText(row map[string]interface{}) map[string]string {
+
+	if len(r.keys) == 0 {
+		return nil
+	}
+
+	text := make(map[string]string, len(r.keys))
+	for _, key := range r.keys {
+		alias := keyTextPrefix + key
+		if v, ok := row[alias]; ok && v != nil {
+			text[key] = keyValueToString(v)
+		}
+
+		delete(row, alias)
+	}
+
+	return text
+}
+
+// readKeyset reads rows following lastKey, it returns key of the last row
+func (r *snapshotReader) readKeyset(lastKey []interface{}, fn func(map[string]interface{}, map[string]string)) (int, []interface{}, error) {
+
+	count, last, err := r.query(r.keysetQuery(lastKey != nil), lastKey, fn)
+	if err != nil || last == nil {
+		return count, nil, err
+	}
+
+	// Keys are kept in text form, so that position can be stored and PostgreSQL converts them to type of columns
+	key := make([]interface{}, len(r.keys))
+	for i, column := range r.keys {
+		key[i] = last[column]
+	}
+
+	return count, key, nil
+}
+
+// readBlocks reads rows in blocks [from, to) of table
+func (r *snapshotReader) readBlocks(from int64, to int64, fn func(map[string]interface{}, map[string]string)) (int, error) {
+
+	sqlStr := fmt.Sprintf("SELECT %s FROM %s%s",
+		r.selectList(),
+		r.table,
+		r.where(
+			fmt.Sprintf("ctid >= '(%d,0)'::tid", from),
+			fmt.Sprintf("ctid < '(%d,0)'::tid", to),
+		),
+	)
+
+	count, _, err := r.query(sqlStr, nil, fn)
+
+	return count, err
+}
+
+// blocks returns number of blocks and estimated rows of table
+func (r *snapshotReader) blocks() (int64, int64, error) {
+
+	var blocks, rows int64
+	err := r.database.db.QueryRow(`SELECT pg_relation_size(c.oid) / current_setting('block_size')::bigint, GREATEST(c.reltuples, 0)::bigint
+		FROM pg_class c WHERE c.oid = $1::regclass`, r.table).Scan(&blocks, &rows)
+
+	return blocks, rows, err
+}
+
+// blocksPerPage estimates how many blocks make a page
+func (r *snapshotReader) blocksPerPage(blocks int64, rows int64) int64 {
+
+	if blocks == 0 || rows == 0 {
+		return 1
+	}
+
+	n := int64(r.pageSize) * blocks / rows
+	if n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+func encodeKeyPosition(key []interface{}) string {
+	values := make([]string, len(key))
+	for i, v := range key {
+		values[i] = keyValueToString(v)
+	}
+
+	b, _ := json.Marshal(values)
+
+	return string(b)
+}
+
+func decodeKeyPosition(position string) []interface{} {
+
+	var values []string
+	if len(position) == 0 || json.Unmarshal([]byte(position), &values) != nil {
+		return nil
+	}
+
+	key := make([]interface{}, len(values))
+	for i, v := range values {
+		key[i] = v
+	}
+
+	return key
+}
+
+func decodeBlockPosition(position string) int64 {
+
+	if !strings.HasPrefix(position, ctidPositionPrefix) {
+		return 0
+	}
+
+	block, _ := strconv.ParseInt(strings.TrimPrefix(position, ctidPositionPrefix), 10, 64)
+
+	return block
+}
+
+// saveSnapshotPosition saves position and progress once rows before position were delivered
+func (database *Database) saveSnapshotPosition(tableName string, position string, progress LoadProgress) {
+
+	if database.source == nil || database.source.state == nil {
+		return
+	}
+
+	database.source.waitForPending()
+	database.source.checkPublishAsyncComplete()
+
+	err := database.source.state.SetSnapshotPosition(tableName, position)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": tableName,
+		}).Error("Failed to save snapshot position: ", err)
+	}
+
+	database.saveProgress(tableName, progress)
+}
+
+// updateProgress counts rows of page which was read
+func (database *Database) updateProgress(sourceName string, tableName string, rows int) LoadProgress {
+
+	progress := database.progress.add(tableName, int64(rows), time.Now())
+	reportProgress(sourceName, tableName, progress)
+
+	log.WithFields(log.Fields{
+		"table":         tableName,
+		"rows":          progress.Rows,
+		"total":         progress.Total,
+		"rowsPerSecond": int64(progress.RowsPerSecond),
+		"eta":           time.Duration(progress.ETASeconds) * time.Second,
+	}).Info("Processing initialLoad")
+
+	return progress
+}
+
+// snapshotByKey reads pages in primary key order
+func (database *Database) snapshotByKey(sourceName string, r *snapshotReader, fn func(*CDCEvent), interval int) {
+
+	tableInfo := database.getTableInfo(r.table)
+	lastKey := decodeKeyPosition(tableInfo.snapshotPosition)
+	if lastKey != nil {
+		log.WithFields(log.Fields{
+			"table":    r.table,
+			"position": tableInfo.snapshotPosition,
+		}).Info("Resuming initialLoad")
+	}
+
+	for !database.stopping {
+
+		database.waitIfPaused(r.table)
+
+		count, key, err := r.readKeyset(lastKey, func(row map[string]interface{}, text map[string]string) {
+
+			e := database.processSnapshotEvent(r.table, row, text)
+
+			// Using primary key with epoch as message ID, so rerun of the same snapshot can be deduplicated
+			pk, _ := EncodePrimaryKey(r.keys, row, text)
+			e.LastLSN = fmt.Sprintf("snapshot-%d-%s", tableInfo.snapshotEpoch, pk)
+
+			fn(e)
+		})
+		if err != nil {
+			log.Error("Initialization Error: ", err)
+			time.Sleep(time.Duration(interval) * time.Second)
+			continue
+		}
+
+		progress := database.updateProgress(sourceName, r.table, count)
+
+		if key == nil || count < r.pageSize {
+			return
+		}
+
+		lastKey = key
+		database.saveSnapshotPosition(r.table, encodeKeyPosition(key), progress)
+	}
+}
+
+// snapshotByBlock reads ranges of blocks for tables without primary key
+func (database *Database) snapshotByBlock(sourceName string, r *snapshotReader, fn func(*CDCEvent), blocks int64, total int64, interval int) {
+
+	tableInfo := database.getTableInfo(r.table)
+	from := decodeBlockPosition(tableInfo.snapshotPosition)
+	if from > 0 {
+		log.WithFields(log.Fields{
+			"table":    r.table,
+			"position": tableInfo.snapshotPosition,
+		}).Info("Resuming initialLoad")
+	}
+
+	// Partitioned table has no block of its own
+	step := r.blocksPerPage(blocks, total)
+	if blocks == 0 {
+		step = 0
+	}
+
+	for !database.stopping {
+
+		database.waitIfPaused(r.table)
+
+		to := from + step
+		i := 0
+		handle := func(row map[string]interface{}, text map[string]string) {
+			i++
+			e := database.processSnapshotEvent(r.table, row, text)
+			e.LastLSN = fmt.Sprintf("%s-%s-%d-%d-%d", sourceName, r.table, tableInfo.snapshotEpoch, from, i)
+			fn(e)
+		}
+
+		var count int
+		var err error
+		if step == 0 {
+			count, _, err = r.query(fmt.Sprintf("SELECT %s FROM %s%s", r.selectList(), r.table, r.where()), nil, handle)
+		} else {
+			count, err = r.readBlocks(from, to, handle)
+		}
+		if err != nil {
+			log.Error("Initialization Error: ", err)
+			time.Sleep(time.Duration(interval) * time.Second)
+			continue
+		}
+
+		log.Debug(fmt.Sprintf("Processing %s initialLoad blocks %d to %d of %d", r.table, from, to, blocks))
+		progress := database.updateProgress(sourceName, r.table, count)
+
+		if step == 0 || to >= blocks {
+			return
+		}
+
+		from = to
+		database.saveSnapshotPosition(r.table, fmt.Sprintf("%s%d", ctidPositionPrefix, from), progress)
+	}
+}
@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotReaderQuery(t *testing.T) {
+
+	database := NewDatabase()
+	database.tables = map[string]SourceTable{
+		"public.orders": SourceTable{
+			Columns: []string{"status", "id", "Amount"},
+			Filter:  "deleted_at IS NULL",
+		},
+		"public.logs": SourceTable{},
+	}
+	database.tableInfo["public.orders"] = tableInfo{
+		primaryKeys: []string{"tenant", "id"},
+	}
+
+	// Keys come first and are not selected twice
+	r := database.newSnapshotReader("public.orders", 100)
+	keys := `"tenant"::text AS "gravity.key.tenant", "id"::text AS "gravity.key.id"`
+	assert.Equal(t, `"tenant", "id", "status", "Amount", `+keys, r.selectList())
+	assert.Equal(t,
+		`SELECT "tenant", "id", "status", "Amount", `+keys+` FROM public.orders WHERE (deleted_at IS NULL) ORDER BY "tenant", "id" LIMIT 100`,
+		r.keysetQuery(false),
+	)
+	assert.Equal(t,
+		`SELECT "tenant", "id", "status", "Amount", `+keys+` FROM public.orders WHERE (deleted_at IS NULL) AND ("tenant", "id") > ($1, $2) ORDER BY "tenant", "id" LIMIT 100`,
+		r.keysetQuery(true),
+	)
+
+	r = database.newSnapshotReader("public.logs", 100)
+	assert.Equal(t, "*", r.selectList())
+	assert.Equal(t, "", r.where())
+	assert.Equal(t, " WHERE ctid >= '(0,0)'::tid", r.where("ctid >= '(0,0)'::tid"))
+	assert.Equal(t, int64(20), r.blocksPerPage(1000, 5000))
+	assert.Equal(t, int64(1), r.blocksPerPage(10, 100000))
+	assert.Equal(t, int64(1), r.blocksPerPage(0, 0))
+}
+
+func TestSnapshotPosition(t *testing.T) {
+
+	position := encodeKeyPosition([]interface{}{int64(1), "a\"b"})
+	assert.Equal(t, `["1","a\"b"]`, position)
+	assert.Equal(t, []interface{}{"1", "a\"b"}, decodeKeyPosition(position))
+	assert.Nil(t, decodeKeyPosition(""))
+	assert.Nil(t, decodeKeyPosition("ctid:10"))
+
+	assert.Equal(t, int64(10), decodeBlockPosition("ctid:10"))
+	assert.Equal(t, int64(0), decodeBlockPosition(position))
+}
+
+func TestProjectColumns(t *testing.T) {
+
+	source := &Source{
+		database: NewDatabase(),
+		tables: map[string]SourceTable{
+			"public.orders": SourceTable{
+				Columns: []string{"status", "note"},
+			},
+		},
+	}
+	source.database.tableInfo["public.orders"] = tableInfo{
+		primaryKeys: []string{"id"},
+	}
+
+	data := map[string]interface{}{
+		"id":     int64(1),
+		"status": "paid",
+		"secret": "x",
+	}
+
+	unchanged := source.projectColumns("public.orders", data, []string{"note", "blob"})
+	assert.Equal(t, map[string]interface{}{"id": int64(1), "status": "paid"}, data)
+	assert.Equal(t, []string{"note"}, unchanged)
+}
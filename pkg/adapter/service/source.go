@@ -4,17 +4,19 @@ import (
 	"context"
 	"fmt"
 	"golang.org/x/time/rate"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
-	"github.com/BrobridgeOrg/broton"
 	"github.com/spf13/viper"
 
-	gravity_adapter "github.com/BrobridgeOrg/gravity-sdk/v2/adapter"
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/monitor"
 	parallel_chunked_flow "github.com/cfsghost/parallel-chunked-flow"
-	"github.com/nats-io/nats.go"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,23 +25,32 @@ var counter uint64
 type Source struct {
 	adapter          *Adapter
 	info             *SourceInfo
-	store            *broton.Store
+	state            SourceState
 	database         *Database
-	connector        *gravity_adapter.AdapterConnector
+	sinks            map[string]Sink
 	incoming         chan *CDCEvent
 	name             string
 	parser           *parallel_chunked_flow.ParallelChunkedFlow
 	tables           map[string]SourceTable
 	stopping         bool
-	ackFutures       []nats.PubAckFuture
 	publishBatchSize uint64
 	rateLimiter      *rate.Limiter
+	scheduler        *requestScheduler
+	leader           *leaderElector
+	pending          int64
+	outboxCleaner    *outboxCleaner
 }
 
 type Packet struct {
-	EventName string
-	Payload   []byte
-	lastLSN   string
+	EventName        string
+	Payload          []byte
+	PrimaryKeys      []string
+	PrimaryKey       string
+	UnchangedColumns []string
+	MessagePrefix    string
+	OutboxID         string
+	Partition        string
+	lastLSN          string
 }
 
 type Request struct {
@@ -122,15 +133,17 @@ func NewSource(adapter *Adapter, name string, sourceInfo *SourceInfo) *Source {
 	source := &Source{
 		adapter:          adapter,
 		info:             sourceInfo,
-		store:            nil,
+		state:            nil,
 		database:         NewDatabase(),
 		incoming:         make(chan *CDCEvent, 64),
 		name:             name,
 		tables:           tables,
 		stopping:         false,
-		ackFutures:       make([]nats.PubAckFuture, 0, publishBatchSize),
 		publishBatchSize: publishBatchSize,
 		rateLimiter:      limiter,
+		scheduler:        newRequestScheduler(tables),
+		sinks:            make(map[string]Sink),
+		outboxCleaner:    newOutboxCleaner(),
 	}
 
 	// Initialize parapllel chunked flow
@@ -142,13 +155,27 @@ func NewSource(adapter *Adapter, name string, sourceInfo *SourceInfo) *Source {
 			cdcEvent := data.(*CDCEvent)
 			defer cdcEventPool.Put(cdcEvent)
 
-			req := source.prepareRequest(cdcEvent)
-			if req == nil {
+			if outbox := source.tables[cdcEvent.Table].Outbox; outbox != nil && outbox.skips(cdcEvent.Operation) {
+				log.WithFields(log.Fields{
+					"table":     cdcEvent.Table,
+					"operation": cdcEvent.Operation,
+				}).Debug("Skip change of outbox row")
+				atomic.AddInt64(&source.pending, -1)
+				return
+			}
+
+			requests := source.prepareRequests(cdcEvent)
+			if len(requests) == 0 {
 				log.Warn("req in nil")
+				atomic.AddInt64(&source.pending, -1)
 				return
 			}
 
-			output(req)
+			// Outbox row might produce more than one request
+			atomic.AddInt64(&source.pending, int64(len(requests)-1))
+			for _, req := range requests {
+				output(req)
+			}
 		},
 	}
 
@@ -161,6 +188,29 @@ func (source *Source) parseEventName(event *CDCEvent) string {
 
 	eventName := ""
 
+	// Heartbeat doesn't belong to any table
+	if event.Operation == HeartbeatOperation {
+		if source.info.Heartbeat == nil {
+			return ""
+		}
+
+		return source.info.Heartbeat.Event
+	}
+
+	// Message is routed by prefix
+	if event.Operation == MessageOperation {
+		return source.info.Messages[event.Table].Event
+	}
+
+	// Control events of all tables share the same name
+	if event.Operation == ControlOperation {
+		if source.info.InitialLoadProgress == nil {
+			return ""
+		}
+
+		return source.info.InitialLoadProgress.Event
+	}
+
 	// determine event name
 	tableInfo, ok := source.tables[event.Table]
 	if !ok {
@@ -176,6 +226,8 @@ func (source *Source) parseEventName(event *CDCEvent) string {
 		eventName = tableInfo.Events.Delete
 	case SnapshotOperation:
 		eventName = tableInfo.Events.Snapshot
+	case TruncateOperation:
+		eventName = tableInfo.Events.Truncate
 	default:
 		return eventName
 	}
@@ -184,97 +236,286 @@ func (source *Source) parseEventName(event *CDCEvent) string {
 }
 
 func (source *Source) Uninit() error {
-	fmt.Println("Stopping ...")
+	fmt.Fprintln(os.Stderr, "Stopping ...")
 	source.stopping = true
 	source.database.stopping = true
+	monitor.RemoveStatus(source.name)
 	time.Sleep(1 * time.Second)
 
 	source.checkPublishAsyncComplete()
-	source.adapter.storeMgr.Close()
+	if source.database.capture != nil {
+		source.database.capture.Close()
+	}
 	return nil
 
 }
 
 func (source *Source) Init() error {
 
-	if viper.GetBool("store.enabled") {
+	err := source.prepare()
+	if err != nil {
+		return err
+	}
+
+	// Getting tables
+	tables := make([]string, 0, len(source.tables))
+	for tableName, _ := range source.tables {
+		tables = append(tables, tableName)
+	}
+
+	log.WithFields(log.Fields{
+		"tables": tables,
+	}).Info("Preparing to watch tables")
+
+	source.startOutboxCleanup()
+
+	monitor.SetStatus(source.name, func() interface{} {
+		return source.currentState()
+	})
+
+	log.Info("Ready to start CDC, tables: ", tables)
+	//err = source.database.StartCDC(source.tables, source.info.InitialLoad, source.info.Interval, func(event *CDCEvent) {
+	if source.info.LeaderElection != nil {
+		source.leader = newLeaderElector(source.database, source.name, source.info.LeaderElection)
+	}
+
+	go func(sourceName string, tables map[string]SourceTable, initialLoad bool, initialLoadBatchSize int, interval int) {
+
+		// Only leader consumes slot and runs initial load
+		if source.leader != nil {
+			source.leader.Campaign()
+			if !source.leader.IsLeader() {
+				return
+			}
+
+			// Exiting so that instance comes back as standby, events which were read already are published first
+			go source.leader.Watch(func(err error) {
+				source.waitForPending()
+				source.checkPublishAsyncComplete()
+				log.WithFields(log.Fields{
+					"source": sourceName,
+				}).Fatal(LostLeadershipErr, ": ", err)
+			})
+		}
+
+		err = source.database.StartCDC(sourceName, tables, initialLoad, initialLoadBatchSize, interval, source.push)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(source.name, source.tables, source.info.InitialLoad, source.info.InitialLoadBatchSize, source.info.Interval)
+
+	return nil
+}
+
+// Snapshot runs initial load of table and waits until all events were acknowledged
+func (source *Source) Snapshot(tableName string) error {
+
+	if _, ok := source.tables[tableName]; !ok {
+		return fmt.Errorf("Table %s is not configured in source %s", tableName, source.name)
+	}
+
+	log.WithFields(log.Fields{
+		"source": source.name,
+		"table":  tableName,
+	}).Info("Starting snapshot")
+
+	err := source.database.SnapshotTable(source.name, tableName, source.push, source.info.InitialLoadBatchSize, source.info.Interval)
+	if err != nil {
+		return err
+	}
+
+	source.waitForPending()
+	source.checkPublishAsyncComplete()
+
+	return nil
+}
+
+// waitForPending blocks until all events in pipeline were handled
+func (source *Source) waitForPending() {
+	for atomic.LoadInt64(&source.pending) > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (source *Source) push(event *CDCEvent) {
+	atomic.AddInt64(&source.pending, 1)
+	source.incoming <- event
+}
+
+func (source *Source) prepare() error {
+
+	if source.adapter.store != nil {
 
 		// Initializing store
-		log.WithFields(log.Fields{
-			"store": "adapter-" + source.name,
-		}).Info("Initializing store for adapter")
-		store, err := source.adapter.storeMgr.GetStore("adapter-" + source.name)
+		state, err := source.adapter.store.SourceState(source.name)
 		if err != nil {
+			log.Error(err)
 			return err
 		}
 
-		source.store = store
+		source.state = state
 
-		// Getting table's lsn
+		// Getting table's state
 		for tableName, _ := range source.tables {
-			// set  default values
-			var initialLoadStatus int64 = 0
-
-			// register columns
-			columns := []string{"status"}
-			err := source.store.RegisterColumns(columns)
-			if err != nil {
-				log.Error(err)
-				return err
-			}
-			// Getting last Time
-			initialLoadStatusCol := fmt.Sprintf("%s-%s", source.name, tableName)
-			initialLoadStatus, err = source.store.GetInt64("status", []byte(initialLoadStatusCol))
+			tableState, err := source.state.GetTableState(tableName)
 			if err != nil {
 				log.Error(err)
 				return err
 			}
 
-			tableInfo := source.database.tableInfo[tableName]
-			if initialLoadStatus != 0 {
-				tableInfo.initialLoaded = true
-			} else {
-				tableInfo.initialLoaded = false
-			}
-			source.database.tableInfo[tableName] = tableInfo
+			source.database.updateTableInfo(tableName, func(info *tableInfo) {
+				info.initialLoaded = tableState.InitialLoaded
+				info.snapshotEpoch = tableState.SnapshotEpoch
+				info.snapshotPosition = tableState.SnapshotPosition
+			})
+
+			source.database.progress.restore(tableName, tableState.Progress)
 		}
 	}
 
-	// Initializing gravity adapter connector
-	source.connector = source.adapter.app.GetAdapterConnector()
+	err := source.prepareSink()
+	if err != nil {
+		return err
+	}
 
 	// Connect to database
-	err := source.database.Connect(source)
+	err = source.database.Connect(source)
+	if err != nil {
+		return err
+	}
+
+	// Loading primary keys of tables
+	err = source.database.LoadPrimaryKeys(source.tables)
+	if err != nil {
+		return err
+	}
+
+	// Recording raw output of replication slot
+	if CaptureEnabled() {
+		err = source.openCapture()
+		if err != nil {
+			return err
+		}
+	}
+
+	source.startWorkers()
+
+	return nil
+}
+
+func (source *Source) prepareSink() error {
+
+	// Initializing sink
+	if source.adapter.sink != nil {
+		source.sinks[""] = source.adapter.sink
+		return nil
+	}
+
+	return source.prepareGravitySinks()
+}
+
+func (source *Source) openCapture() error {
+
+	capture, err := OpenCapture(source.name)
 	if err != nil {
 		return err
 	}
 
+	primaryKeys := make(map[string][]string, len(source.tables))
+	for tableName, _ := range source.tables {
+		primaryKeys[tableName] = source.database.GetPrimaryKeys(tableName)
+	}
+
+	err = capture.WritePrimaryKeys(primaryKeys)
+	if err != nil {
+		capture.Close()
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"source": source.name,
+		"path":   capture.file.Name(),
+	}).Info("Capturing output of replication slot")
+
+	source.database.capture = capture
+
+	return nil
+}
+
+func (source *Source) startWorkers() {
+
 	go source.eventReceiver()
 	go source.requestHandler()
 
 	time.Sleep(time.Second)
+}
 
-	// Getting tables
-	tables := make([]string, 0, len(source.tables))
-	for tableName, _ := range source.tables {
-		tables = append(tables, tableName)
+// Replay feeds rows of capture file through parser and sink without database
+func (source *Source) Replay(r io.Reader) error {
+
+	err := source.prepareSink()
+	if err != nil {
+		return err
 	}
 
-	log.WithFields(log.Fields{
-		"tables": tables,
-	}).Info("Preparing to watch tables")
+	source.database.dbInfo.SlotName = source.info.SlotName
+	source.database.dbInfo.Heartbeat = source.info.Heartbeat
+	source.database.dbInfo.Messages = source.info.Messages
+	source.database.tables = source.tables
 
-	log.Info("Ready to start CDC, tables: ", tables)
-	//err = source.database.StartCDC(source.tables, source.info.InitialLoad, source.info.Interval, func(event *CDCEvent) {
-	go func(sourceName string, tables map[string]SourceTable, initialLoad bool, initialLoadBatchSize int, interval int) {
-		err = source.database.StartCDC(sourceName, tables, initialLoad, initialLoadBatchSize, interval, func(event *CDCEvent) {
+	source.startWorkers()
+
+	count := 0
+	err = ReadCapture(r, func(record *CaptureRecord) error {
+
+		// Using primary keys which were captured with rows
+		if record.PrimaryKeys != nil {
+			source.waitForPending()
+			for tableName, keys := range record.PrimaryKeys {
+				source.database.updateTableInfo(tableName, func(info *tableInfo) {
+					info.primaryKeys = keys
+				})
+			}
+
+			return nil
+		}
+
+		// Using partitions which were resolved while capturing
+		if record.Partitions != nil {
+			for partition, tableName := range record.Partitions {
+				source.database.partitions.set(partition, tableName)
+			}
+
+			return nil
+		}
 
-			source.incoming <- event
-		})
+		events, err := source.database.processEvent(record.Row())
 		if err != nil {
-			log.Fatal(err)
+			if err == UnsupportEventTypeErr || err == EmptyEventTypeErr {
+				return nil
+			}
+
+			return err
 		}
-	}(source.name, source.tables, source.info.InitialLoad, source.info.InitialLoadBatchSize, source.info.Interval)
+
+		for _, e := range events {
+			count++
+			source.push(e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	source.waitForPending()
+	source.checkPublishAsyncComplete()
+
+	log.WithFields(log.Fields{
+		"source": source.name,
+		"events": count,
+	}).Info("Replay completed")
 
 	return nil
 }
@@ -304,6 +545,8 @@ func (source *Source) eventReceiver() {
 
 func (source *Source) requestHandler() {
 
+	go source.requestPublisher()
+
 	for {
 		select {
 		case req := <-source.parser.Output():
@@ -314,11 +557,48 @@ func (source *Source) requestHandler() {
 					break
 				}
 			*/
-			request := req.(*Request)
-			source.HandleRequest(request)
-			requestPool.Put(request)
+			source.scheduler.push(req.(*Request))
+		}
+	}
+}
+
+// requestPublisher publishes requests in the order which scheduler decides
+func (source *Source) requestPublisher() {
+
+	for {
+		request := source.scheduler.pop()
+		source.HandleRequest(request)
+		requestPool.Put(request)
+		atomic.AddInt64(&source.pending, -1)
+	}
+}
+
+func (source *Source) prepareRequests(event *CDCEvent) []*Request {
+
+	requests := make([]*Request, 0, 2)
+
+	outbox := source.tables[event.Table].Outbox
+	if outbox != nil && event.Operation == InsertOperation {
+		req, err := outbox.request(event)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"table": event.Table,
+			}).Error("Invalid outbox row: ", err)
+		} else {
+			requests = append(requests, req)
 		}
 	}
+
+	if outbox != nil && outbox.SkipRow && event.Operation != ControlOperation {
+		return requests
+	}
+
+	req := source.prepareRequest(event)
+	if req != nil {
+		requests = append(requests, req)
+	}
+
+	return requests
 }
 
 func (source *Source) prepareRequest(event *CDCEvent) *Request {
@@ -329,9 +609,32 @@ func (source *Source) prepareRequest(event *CDCEvent) *Request {
 		return nil
 	}
 
+	// Content of message is published as it is
+	if event.Operation == MessageOperation {
+		request := requestPool.Get().(*Request)
+		request.Time = event.Time
+		request.Table = event.Table
+
+		request.Req.EventName = eventName
+		request.Req.Payload = event.Payload
+		request.Req.PrimaryKeys = nil
+		request.Req.PrimaryKey = ""
+		request.Req.UnchangedColumns = nil
+		request.Req.MessagePrefix = event.Table
+		request.Req.OutboxID = ""
+		request.Req.Partition = ""
+		request.Req.lastLSN = event.LastLSN
+
+		return request
+	}
+
 	// Prepare payload
 	data := dataPool.Get().(map[string]interface{})
 	defer dataPool.Put(data)
+	for k := range data {
+		delete(data, k)
+	}
+
 	for k, v := range event.Before {
 		data[k] = v
 	}
@@ -340,6 +643,35 @@ func (source *Source) prepareRequest(event *CDCEvent) *Request {
 		data[k] = v
 	}
 
+	// Getting primary key, truncate and control events are not about any row
+	var primaryKeys []string
+	if event.Operation != TruncateOperation && event.Operation != ControlOperation {
+		primaryKeys = source.database.GetPrimaryKeys(event.Table)
+	}
+
+	primaryKey, err := EncodePrimaryKey(primaryKeys, data, event.TextData)
+	if err != nil {
+		// Delete event is useless without key
+		if event.Operation == DeleteOperation {
+			log.WithFields(log.Fields{
+				"table": event.Table,
+				"keys":  primaryKeys,
+			}).Warn("Skip delete event: ", err)
+			return nil
+		}
+
+		log.WithFields(log.Fields{
+			"table": event.Table,
+			"keys":  primaryKeys,
+		}).Warn(err)
+	}
+
+	unchangedColumns := source.resolveUnchangedToast(event, data)
+
+	if event.Operation != TruncateOperation && event.Operation != ControlOperation {
+		unchangedColumns = source.projectColumns(event.Table, data, unchangedColumns)
+	}
+
 	payload, err := json.Marshal(data)
 	if err != nil {
 		log.Error(err)
@@ -353,11 +685,79 @@ func (source *Source) prepareRequest(event *CDCEvent) *Request {
 
 	request.Req.EventName = eventName
 	request.Req.Payload = payload
+	request.Req.PrimaryKeys = primaryKeys
+	request.Req.PrimaryKey = primaryKey
+	request.Req.UnchangedColumns = unchangedColumns
+	request.Req.MessagePrefix = ""
+	request.Req.OutboxID = ""
+	request.Req.Partition = event.Partition
 	request.Req.lastLSN = event.LastLSN
 
 	return request
 }
 
+// projectColumns removes columns which are not configured for table, primary keys are always kept
+func (source *Source) projectColumns(tableName string, data map[string]interface{}, unchangedColumns []string) []string {
+
+	columns := source.tables[tableName].Columns
+	if len(columns) == 0 {
+		return unchangedColumns
+	}
+
+	keys := source.database.GetPrimaryKeys(tableName)
+	projected := func(column string) bool {
+		return containsString(columns, column) || containsString(keys, column)
+	}
+
+	for k := range data {
+		if !projected(k) {
+			delete(data, k)
+		}
+	}
+
+	if unchangedColumns == nil {
+		return nil
+	}
+
+	flagged := make([]string, 0, len(unchangedColumns))
+	for _, column := range unchangedColumns {
+		if projected(column) {
+			flagged = append(flagged, column)
+		}
+	}
+
+	return flagged
+}
+
+// resolveUnchangedToast returns columns which are not in payload and should be flagged
+func (source *Source) resolveUnchangedToast(event *CDCEvent, data map[string]interface{}) []string {
+
+	if len(event.UnchangedToast) == 0 {
+		return nil
+	}
+
+	switch source.tables[event.Table].UnchangedToast {
+	case FlagUnchangedToast:
+		return event.UnchangedToast
+	case FetchUnchangedToast:
+		values, err := source.database.fetchUnchangedToast(event.Table, event.UnchangedToast, data)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"table":   event.Table,
+				"columns": event.UnchangedToast,
+			}).Warn("Failed to fetch unchanged TOAST values: ", err)
+
+			return event.UnchangedToast
+		}
+
+		for k, v := range values {
+			data[k] = v
+		}
+	}
+
+	return nil
+}
+
 func (source *Source) HandleRequest(request *Request) {
 
 	if source.stopping {
@@ -368,17 +768,49 @@ func (source *Source) HandleRequest(request *Request) {
 	meta := metaPool.Get().(map[string]string)
 	meta["Nats-Msg-Id"] = fmt.Sprintf("%s-%s-%s", source.name, request.Table, request.Req.lastLSN)
 	log.Trace("Nats-Msg-Id: ", meta["Nats-Msg-Id"])
+	if len(request.Req.PrimaryKeys) > 0 && len(request.Req.PrimaryKey) > 0 {
+		meta[PrimaryKeysHeader] = strings.Join(request.Req.PrimaryKeys, ",")
+		meta[PrimaryKeyValueHeader] = request.Req.PrimaryKey
+	} else {
+		delete(meta, PrimaryKeysHeader)
+		delete(meta, PrimaryKeyValueHeader)
+	}
+	if len(request.Req.UnchangedColumns) > 0 {
+		meta[UnchangedColumnsHeader] = strings.Join(request.Req.UnchangedColumns, ",")
+	} else {
+		delete(meta, UnchangedColumnsHeader)
+	}
+	if len(request.Req.Partition) > 0 {
+		meta[PartitionHeader] = request.Req.Partition
+	} else {
+		delete(meta, PartitionHeader)
+	}
+	if source.leader != nil {
+		meta[FencingTokenHeader] = strconv.FormatInt(source.leader.Token(), 10)
+	} else {
+		delete(meta, FencingTokenHeader)
+	}
+	if len(request.Req.MessagePrefix) > 0 {
+		meta[MessagePrefixHeader] = request.Req.MessagePrefix
+	} else {
+		delete(meta, MessagePrefixHeader)
+	}
 	for {
 		// Using new SDK to re-implement this part
 		source.rateLimiter.Wait(context.Background())
-		future, err := source.connector.PublishAsync(request.Req.EventName, request.Req.Payload, meta)
+		sink := source.sinkOf(request.Table)
+		err := sink.PublishAsync(request.Req.EventName, request.Req.Payload, meta)
 		if err != nil {
 			log.Error("Failed to get publish Request:", err)
 			log.Debug("EventName: ", request.Req.EventName, " Payload: ", string(request.Req.Payload))
 			time.Sleep(time.Second)
 			continue
 		}
-		source.ackFutures = append(source.ackFutures, future)
+
+		// Outbox row can be deleted once it was acknowledged
+		if len(request.Req.OutboxID) > 0 && source.tables[request.Table].Outbox.CleanupInterval > 0 && sink.Acknowledges() {
+			source.outboxCleaner.add(request.Table, request.Req.OutboxID)
+		}
 
 		log.Debug("EventName: ", request.Req.EventName)
 		log.Trace("Payload: ", string(request.Req.Payload))
@@ -389,58 +821,14 @@ func (source *Source) HandleRequest(request *Request) {
 	}
 
 	if atomic.LoadUint64((*uint64)(&counter))%source.publishBatchSize == 0 {
-		lastFuture := 0
-		isError := false
-	RETRY:
-		for i, future := range source.ackFutures {
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			select {
-			case <-future.Ok():
-				//log.Infof("Message %d acknowledged: %+v", i, pubAck)
-			case <-ctx.Done():
-				log.Warnf("Failed to publish message, retry ...")
-				lastFuture = i
-				isError = true
-				cancel()
-				break RETRY
-			}
-			cancel()
-		}
-		if isError {
-			source.connector.GetJetStream().CleanupPublisher()
-			log.Trace("start retry ...  ", len(source.ackFutures[lastFuture:]))
-			for _, future := range source.ackFutures[lastFuture:] {
-				// send msg with Sync mode
-				for {
-					_, err := source.connector.GetJetStream().PublishMsg(future.Msg())
-					if err != nil {
-						log.Warn(err, ", retry ...")
-						time.Sleep(time.Second)
-						continue
-					}
-					break
-				}
-
-			}
-			log.Trace("retry done")
-
-		}
-		source.ackFutures = source.ackFutures[:0]
+		source.waitForAcks()
 	}
 }
 
 func (source *Source) checkPublishAsyncComplete() {
 	// timeout 60s
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	select {
-	case <-source.connector.PublishAsyncComplete():
-		//log.Info("All messages acknowledged.")
-	case <-ctx.Done():
-		// if the context timeout or canceled, ctx.Done() will return.
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Error("Timeout waiting for acknowledgements. AsyncPending: ", source.connector.GetJetStream().PublishAsyncPending())
-		}
+	err := source.flushSinks(60 * time.Second)
+	if err != nil {
+		log.Error(err)
 	}
 }
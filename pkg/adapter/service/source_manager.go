@@ -2,9 +2,10 @@ package adapter
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
-	"strings"
+	"reflect"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -15,22 +16,41 @@ type SourceConfig struct {
 }
 
 type SourceInfo struct {
-	Disabled             bool                   `json:"disabled"`
-	InitialLoad          bool                   `json:"initialLoad"`
-	InitialLoadBatchSize int                    `json:"initialLoadBatchSize"`
-	Host                 string                 `json:"host"`
-	Port                 int                    `json:"port"`
-	Username             string                 `json:"username"`
-	Password             string                 `json:"password"`
-	DBName               string                 `json:"dbname"`
-	Interval             int                    `json:"interval"`
-	Param                string                 `json:"param"`
-	SlotName             string                 `json:"slotName"`
-	Tables               map[string]SourceTable `json:"tables"`
+	Disabled             bool                       `json:"disabled"`
+	InitialLoad          bool                       `json:"initialLoad"`
+	InitialLoadBatchSize int                        `json:"initialLoadBatchSize"`
+	InitialLoadProgress  *SourceInitialLoadProgress `json:"initialLoadProgress"`
+	Host                 string                     `json:"host"`
+	Port                 int                        `json:"port"`
+	Username             string                     `json:"username"`
+	Password             string                     `json:"password"`
+	PasswordSecret       *SecretSource              `json:"passwordSecret"`
+	DBName               string                     `json:"dbname"`
+	Interval             int                        `json:"interval"`
+	MaxChangesPerRead    int                        `json:"maxChangesPerRead"`
+	MaxBytesPerRead      int64                      `json:"maxBytesPerRead"`
+	SnapshotChunkSize    int                        `json:"snapshotChunkSize"`
+	Param                string                     `json:"param"`
+	TLS                  *SourceTLS                 `json:"tls"`
+	SlotName             string                     `json:"slotName"`
+	Domain               string                     `json:"domain"`
+	LeaderElection       *SourceLeaderElection      `json:"leaderElection"`
+	Signal               *SourceSignal              `json:"signal"`
+	Heartbeat            *SourceHeartbeat           `json:"heartbeat"`
+	SlotMonitor          *SlotMonitor               `json:"slotMonitor"`
+	Messages             map[string]SourceMessage   `json:"messages"`
+	Tables               map[string]SourceTable     `json:"tables"`
 }
 
 type SourceTable struct {
-	Events SourceTableEvents `json:"events"`
+	Events         SourceTableEvents `json:"events"`
+	UnchangedToast string            `json:"unchangedToast"`
+	Outbox         *SourceOutbox     `json:"outbox"`
+	RateLimit      float64           `json:"rateLimit"`
+	Priority       int               `json:"priority"`
+	Domain         string            `json:"domain"`
+	Columns        []string          `json:"columns"`
+	Filter         string            `json:"filter"`
 }
 
 type SourceTableEvents struct {
@@ -38,6 +58,7 @@ type SourceTableEvents struct {
 	Create   string `json:"create"`
 	Update   string `json:"update"`
 	Delete   string `json:"delete"`
+	Truncate string `json:"truncate"`
 }
 
 type SourceManager struct {
@@ -73,21 +94,19 @@ func (sm *SourceManager) Initialize() error {
 			"port": info.Port,
 		}).Info("Initializing source")
 
-		pwdFromEnvKey := fmt.Sprintf("%s_PASSWORD", strings.ToUpper(name))
-		pwdFromEnvValue := os.Getenv(pwdFromEnvKey)
-		if pwdFromEnvValue != "" {
-			pwd, err := AesDecrypt(pwdFromEnvValue)
-			if err != nil {
-				log.Error(err)
-				return err
-			}
-
-			info.Password = pwd
+		sourceInfo := info
+		err := ResolvePassword(name, &sourceInfo)
+		if err != nil {
+			log.Error(err)
+			return err
 		}
 
-		sourceInfo := info
 		source := NewSource(sm.adapter, name, &sourceInfo)
-		err := source.Init()
+		if source == nil {
+			return fmt.Errorf("Invalid source %s", name)
+		}
+
+		err = source.Init()
 		if err != nil {
 			log.Error(err)
 			return err
@@ -100,22 +119,73 @@ func (sm *SourceManager) Initialize() error {
 }
 
 func (sm *SourceManager) Uninit() error {
+
+	// Configuration file might be changed since started, so stopping sources which are running
+	for _, source := range sm.sources {
+		source.Uninit()
+	}
+	return nil
+}
+
+func (sm *SourceManager) newSource(sourceName string) (*Source, error) {
+
 	// Loading configuration file
 	config, err := sm.LoadSourceConfig(viper.GetString("source.config"))
+	if err != nil {
+		return nil, err
+	}
+
+	info, ok := config.Sources[sourceName]
+	if !ok {
+		return nil, fmt.Errorf("Source %s not found", sourceName)
+	}
+
+	source := NewSource(sm.adapter, sourceName, &info)
+	if source == nil {
+		return nil, fmt.Errorf("Invalid source %s", sourceName)
+	}
+
+	sm.sources[sourceName] = source
+
+	return source, nil
+}
+
+func (sm *SourceManager) Snapshot(sourceName string, tableName string) error {
+
+	source, err := sm.newSource(sourceName)
 	if err != nil {
 		return err
 	}
 
-	// Initializing sources
-	for name, _ := range config.Sources {
-		if source, ok := sm.sources[name]; ok {
-			source.Uninit()
-		}
+	err = ResolvePassword(sourceName, source.info)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	err = source.prepare()
+	if err != nil {
+		return err
+	}
+
+	return source.Snapshot(tableName)
+}
+
+// Replay processes capture file with settings of source, database is not required.
+func (sm *SourceManager) Replay(sourceName string, r io.Reader) error {
+
+	source, err := sm.newSource(sourceName)
+	if err != nil {
+		return err
+	}
+
+	return source.Replay(r)
 }
 
 func (sm *SourceManager) LoadSourceConfig(filename string) (*SourceConfig, error) {
+	return LoadSourceConfig(filename)
+}
+
+func LoadSourceConfig(filename string) (*SourceConfig, error) {
 
 	// Open configuration file
 	jsonFile, err := os.Open(filename)
@@ -126,11 +196,53 @@ func (sm *SourceManager) LoadSourceConfig(filename string) (*SourceConfig, error
 	defer jsonFile.Close()
 
 	// Read
-	byteValue, _ := ioutil.ReadAll(jsonFile)
+	byteValue, err := ioutil.ReadAll(jsonFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSourceConfig(byteValue)
+}
+
+// ParseSourceConfig decodes and validates configuration, all problems will be reported together
+func ParseSourceConfig(data []byte) (*SourceConfig, error) {
+
+	var doc interface{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid source config: %v", err)
+	}
+
+	errs := validateSchema("", doc, reflect.TypeOf(SourceConfig{}))
+
+	// Decoding what is left once values of wrong type were dropped, so that the rest can be checked as well
+	if len(errs) > 0 {
+		data, err = json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid source config: %v", err)
+		}
+	}
 
 	var config SourceConfig
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid source config: %v", err)
+	}
+
+	// Problems of values which were dropped are reported by schema already
+	for _, err := range ValidateSourceConfig(&config) {
+		if e, ok := err.(*ConfigError); ok && reportedPath(e.Path, errs) {
+			continue
+		}
 
-	json.Unmarshal(byteValue, &config)
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return &config, &ConfigValidationErr{
+			Errors: errs,
+		}
+	}
 
 	return &config, nil
 }
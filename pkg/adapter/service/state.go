@@ -0,0 +1,212 @@
+package adapter
+
+import (
+	"fmt"
+
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/app"
+	"github.com/BrobridgeOrg/broton"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	LocalStateStore     = "local"
+	JetStreamStateStore = "jetstream"
+)
+
+type TableState struct {
+	InitialLoaded    bool          `json:"initialLoaded"`
+	SnapshotEpoch    int64         `json:"snapshotEpoch"`
+	SnapshotPosition string        `json:"snapshotPosition,omitempty"`
+	Progress         *LoadProgress `json:"progress,omitempty"`
+}
+
+// StateStore keeps state of sources
+type StateStore interface {
+	SourceState(sourceName string) (SourceState, error)
+	Close() error
+}
+
+// SourceState keeps state of tables in a source
+type SourceState interface {
+	GetTableState(tableName string) (*TableState, error)
+
+	// SetInitialLoaded marks table as loaded and moves on to the next snapshot epoch.
+	SetInitialLoaded(tableName string, snapshotEpoch int64) error
+
+	// ResetTable makes initial load of table run again on next start.
+	ResetTable(tableName string) error
+
+	// SetSnapshotPosition saves how far initial load has gone, so that it can be resumed.
+	SetSnapshotPosition(tableName string, position string) error
+
+	// SetLoadProgress saves progress of initial load, it is kept after load was completed.
+	SetLoadProgress(tableName string, progress *LoadProgress) error
+}
+
+// StateStoreType returns type of state store from configuration
+func StateStoreType() string {
+	viper.SetDefault("store.type", LocalStateStore)
+	return viper.GetString("store.type")
+}
+
+// OpenStateStore opens state store which is selected by store.type
+func OpenStateStore(a app.App) (StateStore, error) {
+
+	viper.SetDefault("store.path", "./store")
+
+	switch StateStoreType() {
+	case LocalStateStore:
+		return OpenLocalStore(viper.GetString("store.path"))
+	case JetStreamStateStore:
+		return OpenKVStore(a)
+	}
+
+	return nil, fmt.Errorf("Unsupported store type: %s", StateStoreType())
+}
+
+// LocalStore keeps state with broton on persistent volume
+type LocalStore struct {
+	storeMgr *broton.Broton
+}
+
+type localSourceState struct {
+	name  string
+	store *broton.Store
+}
+
+func OpenLocalStore(path string) (*LocalStore, error) {
+
+	options := broton.NewOptions()
+	options.DatabasePath = path
+
+	log.WithFields(log.Fields{
+		"path": options.DatabasePath,
+	}).Info("Initializing store")
+
+	storeMgr, err := broton.NewBroton(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		storeMgr: storeMgr,
+	}, nil
+}
+
+func (ls *LocalStore) SourceState(sourceName string) (SourceState, error) {
+
+	log.WithFields(log.Fields{
+		"store": "adapter-" + sourceName,
+	}).Info("Initializing store for adapter")
+
+	store, err := ls.storeMgr.GetStore("adapter-" + sourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// register columns
+	columns := []string{"status", "epoch", "position", "progress"}
+	err = store.RegisterColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localSourceState{
+		name:  sourceName,
+		store: store,
+	}, nil
+}
+
+func (ls *LocalStore) Close() error {
+	ls.storeMgr.Close()
+	return nil
+}
+
+func (state *localSourceState) tableKey(tableName string) []byte {
+	return []byte(fmt.Sprintf("%s-%s", state.name, tableName))
+}
+
+func (state *localSourceState) GetTableState(tableName string) (*TableState, error) {
+
+	key := state.tableKey(tableName)
+
+	initialLoadStatus, err := state.store.GetInt64("status", key)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotEpoch, err := state.store.GetInt64("epoch", key)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := state.store.GetString("position", key)
+	if err != nil {
+		return nil, err
+	}
+
+	tableState := &TableState{
+		InitialLoaded:    initialLoadStatus != 0,
+		SnapshotEpoch:    snapshotEpoch,
+		SnapshotPosition: position,
+	}
+
+	progress, err := state.store.GetBytes("progress", key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(progress) > 0 {
+		tableState.Progress = &LoadProgress{}
+		err = json.Unmarshal(progress, tableState.Progress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tableState, nil
+}
+
+func (state *localSourceState) SetInitialLoaded(tableName string, snapshotEpoch int64) error {
+
+	key := state.tableKey(tableName)
+
+	err := state.store.PutInt64("status", key, 1)
+	if err != nil {
+		return err
+	}
+
+	err = state.store.PutInt64("epoch", key, snapshotEpoch+1)
+	if err != nil {
+		return err
+	}
+
+	return state.store.PutString("position", key, "")
+}
+
+func (state *localSourceState) ResetTable(tableName string) error {
+
+	key := state.tableKey(tableName)
+
+	err := state.store.PutInt64("status", key, 0)
+	if err != nil {
+		return err
+	}
+
+	return state.store.PutString("position", key, "")
+}
+
+func (state *localSourceState) SetSnapshotPosition(tableName string, position string) error {
+	return state.store.PutString("position", state.tableKey(tableName), position)
+}
+
+func (state *localSourceState) SetLoadProgress(tableName string, progress *LoadProgress) error {
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	return state.store.Put("progress", state.tableKey(tableName), data)
+}
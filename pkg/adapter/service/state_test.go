@@ -0,0 +1,117 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+
+	store, err := OpenLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	defer store.Close()
+
+	state, err := store.SourceState("a")
+	assert.Nil(t, err)
+
+	tableState, err := state.GetTableState("public.orders")
+	assert.Nil(t, err)
+	assert.Equal(t, &TableState{}, tableState)
+
+	assert.Nil(t, state.SetSnapshotPosition("public.orders", `["100"]`))
+
+	tableState, err = state.GetTableState("public.orders")
+	assert.Nil(t, err)
+	assert.Equal(t, `["100"]`, tableState.SnapshotPosition)
+	assert.Nil(t, tableState.Progress)
+
+	assert.Nil(t, state.SetLoadProgress("public.orders", &LoadProgress{Status: LoadRunning, Rows: 100}))
+
+	assert.Nil(t, state.SetInitialLoaded("public.orders", 0))
+	assert.Nil(t, state.ResetTable("public.orders"))
+
+	tableState, err = state.GetTableState("public.orders")
+	assert.Nil(t, err)
+	assert.Equal(t, &TableState{InitialLoaded: false, SnapshotEpoch: 1, Progress: &LoadProgress{Status: LoadRunning, Rows: 100}}, tableState)
+}
+
+func TestKVKeyToken(t *testing.T) {
+	assert.Equal(t, "public.orders", kvKeyToken("public.orders"))
+	assert.Equal(t, "=InB1YmxpYyIuIk15IFRhYmxlIg", kvKeyToken(`"public"."My Table"`))
+
+	// Name can't pass for encoded one
+	assert.Equal(t, "=PXB1YmxpYw", kvKeyToken("=public"))
+	assert.Equal(t, "a=b", kvKeyToken("a=b"))
+}
+
+type fakeKVEntry struct {
+	nats.KeyValueEntry
+	value []byte
+}
+
+func (e *fakeKVEntry) Value() []byte {
+	return e.value
+}
+
+type fakeKVBucket map[string][]byte
+
+func (b fakeKVBucket) Get(key string) (nats.KeyValueEntry, error) {
+
+	value, ok := b[key]
+	if !ok {
+		return nil, nats.ErrKeyNotFound
+	}
+
+	return &fakeKVEntry{value: value}, nil
+}
+
+func (b fakeKVBucket) Put(key string, value []byte) (uint64, error) {
+	b[key] = value
+	return uint64(len(b)), nil
+}
+
+func TestKVStateMigration(t *testing.T) {
+
+	store, err := OpenLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	defer store.Close()
+
+	legacy, err := store.SourceState("a")
+	assert.Nil(t, err)
+	assert.Nil(t, legacy.SetInitialLoaded("public.orders", 2))
+	assert.Nil(t, legacy.SetSnapshotPosition("public.users", `["7"]`))
+
+	bucket := fakeKVBucket{}
+	state := &kvSourceState{
+		name:   "a",
+		kv:     bucket,
+		legacy: legacy,
+	}
+
+	// Loaded table is migrated
+	tableState, err := state.GetTableState("public.orders")
+	assert.Nil(t, err)
+	assert.Equal(t, &TableState{InitialLoaded: true, SnapshotEpoch: 3}, tableState)
+	assert.Contains(t, bucket, "adapter.a.tables.public.orders")
+
+	// Table which was never loaded is left in local store
+	tableState, err = state.GetTableState("public.users")
+	assert.Nil(t, err)
+	assert.Equal(t, `["7"]`, tableState.SnapshotPosition)
+	assert.NotContains(t, bucket, "adapter.a.tables.public.users")
+
+	// Bucket wins once table was migrated
+	assert.Nil(t, legacy.ResetTable("public.orders"))
+
+	tableState, err = state.GetTableState("public.orders")
+	assert.Nil(t, err)
+	assert.True(t, tableState.InitialLoaded)
+	assert.Equal(t, int64(3), tableState.SnapshotEpoch)
+
+	assert.Nil(t, state.SetSnapshotPosition("public.orders", `["9"]`))
+	tableState, err = state.GetTableState("public.orders")
+	assert.Nil(t, err)
+	assert.Equal(t, `["9"]`, tableState.SnapshotPosition)
+}
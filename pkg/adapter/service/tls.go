@@ -0,0 +1,229 @@
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultTLSReloadInterval = 30
+)
+
+var (
+	InvalidCAFileErr = errors.New("No certificate was found in CA file")
+	KeyPermissionErr = errors.New("Private key file must not have group or world access")
+)
+
+type SourceTLS struct {
+	Mode           string `json:"mode"`
+	CAFile         string `json:"caFile"`
+	CertFile       string `json:"certFile"`
+	KeyFile        string `json:"keyFile"`
+	ServerName     string `json:"serverName"`
+	ReloadInterval int    `json:"reloadInterval"`
+}
+
+// Params returns connection parameters for lib/pq
+func (t *SourceTLS) Params() url.Values {
+
+	params := url.Values{}
+	params.Set("sslmode", t.Mode)
+
+	if len(t.CAFile) > 0 {
+		params.Set("sslrootcert", t.CAFile)
+	}
+
+	if len(t.CertFile) > 0 {
+		params.Set("sslcert", t.CertFile)
+		params.Set("sslkey", t.KeyFile)
+	}
+
+	return params
+}
+
+func (t *SourceTLS) files() []string {
+
+	files := make([]string, 0, 3)
+	for _, file := range []string{t.CAFile, t.CertFile, t.KeyFile} {
+		if len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// Verify loads certificate files to make sure they can be used by connections
+func (t *SourceTLS) Verify() error {
+
+	if len(t.CAFile) > 0 {
+		data, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%s: %v", t.CAFile, InvalidCAFileErr)
+		}
+	}
+
+	if len(t.CertFile) > 0 {
+		_, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return err
+		}
+
+		// lib/pq refuses key which is readable by others
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(t.KeyFile)
+			if err != nil {
+				return err
+			}
+
+			if info.Mode().Perm()&0077 != 0 {
+				return fmt.Errorf("%s: %v", t.KeyFile, KeyPermissionErr)
+			}
+		}
+	}
+
+	return nil
+}
+
+// signature changes whenever one of certificate files is replaced
+func (t *SourceTLS) signature() string {
+
+	parts := make([]string, 0, 3)
+	for _, file := range t.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			parts = append(parts, file+":missing")
+			continue
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", file, info.Size(), info.ModTime().UnixNano()))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func validateSourceTLS(path string, t *SourceTLS) []error {
+
+	errs := make([]error, 0)
+	report := func(field string, message string) {
+		errs = append(errs, &ConfigError{
+			Path:    configPath(path, field),
+			Message: message,
+		})
+	}
+
+	switch t.Mode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		report("mode", "must be one of disable, require, verify-ca and verify-full")
+	}
+
+	if len(t.CertFile) > 0 && len(t.KeyFile) == 0 {
+		report("keyFile", "required when certFile is set")
+	}
+
+	if len(t.KeyFile) > 0 && len(t.CertFile) == 0 {
+		report("certFile", "required when keyFile is set")
+	}
+
+	if len(t.ServerName) > 0 && t.Mode != "verify-full" {
+		report("serverName", "only takes effect with verify-full mode")
+	}
+
+	if t.ReloadInterval < 0 {
+		report("reloadInterval", "must not be negative")
+	}
+
+	return errs
+}
+
+// sourceDialer connects to the real host when server name is different from it
+type sourceDialer struct {
+	address string
+	dialer  net.Dialer
+}
+
+func (d *sourceDialer) target(address string) string {
+	if len(d.address) > 0 {
+		return d.address
+	}
+
+	return address
+}
+
+func (d *sourceDialer) Dial(network string, address string) (net.Conn, error) {
+	return d.dialer.Dial(network, d.target(address))
+}
+
+func (d *sourceDialer) DialTimeout(network string, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.dialer.DialContext(ctx, network, d.target(address))
+}
+
+type sourceConnector struct {
+	dsn    string
+	dialer *sourceDialer
+}
+
+func (c *sourceConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return pq.DialOpen(c.dialer, c.dsn)
+}
+
+func (c *sourceConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// watchCertificates drops idle connections once certificate files were replaced,
+// so that new connections will be established with new certificates.
+func (database *Database) watchCertificates(t *SourceTLS) {
+
+	interval := t.ReloadInterval
+	if interval == 0 {
+		interval = DefaultTLSReloadInterval
+	}
+
+	signature := t.signature()
+	for !database.stopping {
+		time.Sleep(time.Duration(interval) * time.Second)
+
+		s := t.signature()
+		if s == signature {
+			continue
+		}
+
+		err := t.Verify()
+		if err != nil {
+			log.Error("Failed to reload certificates: ", err)
+			continue
+		}
+
+		signature = s
+
+		log.WithFields(log.Fields{
+			"files": t.files(),
+		}).Info("Certificates were changed, reconnecting to database")
+
+		database.db.SetMaxIdleConns(0)
+		database.db.SetMaxIdleConns(DefaultMaxIdleConns)
+	}
+}
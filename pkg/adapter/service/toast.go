@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	OmitUnchangedToast  = "omit"
+	FlagUnchangedToast  = "flag"
+	FetchUnchangedToast = "fetch"
+
+	UnchangedColumnsHeader = "Gravity-Unchanged-Columns"
+)
+
+var (
+	RowNotFoundErr = errors.New("Row was not found")
+)
+
+// quoteColumn quotes column name which test_decoding printed, it is quoted already only if
+// it requires quoting.
+func quoteColumn(column string) string {
+
+	if len(column) >= 2 && strings.HasPrefix(column, `"`) && strings.HasSuffix(column, `"`) {
+		column = strings.ReplaceAll(column[1:len(column)-1], `""`, `"`)
+	}
+
+	return pq.QuoteIdentifier(column)
+}
+
+// fetchUnchangedToast reads current values of columns by primary key
+func (database *Database) fetchUnchangedToast(tableName string, columns []string, data map[string]interface{}) (map[string]interface{}, error) {
+
+	keys := database.GetPrimaryKeys(tableName)
+	if len(keys) == 0 {
+		return nil, MissingPrimaryKeyErr
+	}
+
+	conditions := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		v, ok := data[key]
+		if !ok || v == nil {
+			return nil, MissingPrimaryKeyErr
+		}
+
+		conditions[i] = fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(key), i+1)
+		args[i] = v
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteColumn(column)
+	}
+
+	sqlStr := fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(quoted, ", "),
+		tableName,
+		strings.Join(conditions, " AND "),
+	)
+
+	rows, err := database.db.Queryx(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, RowNotFoundErr
+	}
+
+	values, err := rows.SliceScan()
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+
+		// Keeping the same type as parser does, only bytea is binary
+		if b, ok := values[i].([]byte); ok && types[i].DatabaseTypeName() != "BYTEA" {
+			result[column] = string(b)
+			continue
+		}
+
+		result[column] = values[i]
+	}
+
+	return result, nil
+}
+
+func validateUnchangedToast(mode string) bool {
+	switch mode {
+	case "", OmitUnchangedToast, FlagUnchangedToast, FetchUnchangedToast:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,15 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteColumn(t *testing.T) {
+
+	assert.Equal(t, `"note"`, quoteColumn("note"))
+	assert.Equal(t, `"Amount"`, quoteColumn(`"Amount"`))
+	assert.Equal(t, `"a""b"`, quoteColumn(`"a""b"`))
+	assert.Equal(t, `"note; DROP TABLE x"`, quoteColumn("note; DROP TABLE x"))
+}
@@ -2,8 +2,11 @@ package instance
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	adapter_service "git.brobridge.com/gravity/gravity-adapter-postgres/pkg/adapter/service"
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/gravity"
 	gravity_adapter "github.com/BrobridgeOrg/gravity-sdk/v2/adapter"
 	"github.com/BrobridgeOrg/gravity-sdk/v2/core"
 
@@ -19,6 +22,14 @@ const (
 
 func (a *AppInstance) initAdapterConnector() error {
 
+	// Local sinks don't need gravity unless state is kept in it
+	if adapter_service.SinkType() != adapter_service.GravitySinkType && !stateStoreNeedsGravity() {
+		log.WithFields(log.Fields{
+			"sink": adapter_service.SinkType(),
+		}).Info("Gravity connection is skipped")
+		return nil
+	}
+
 	// default settings
 	viper.SetDefault("gravity.domain", "gravity")
 	viper.SetDefault("gravity.pingInterval", DefaultPingInterval)
@@ -42,6 +53,18 @@ func (a *AppInstance) initAdapterConnector() error {
 	options.MaxReconnects = maxReconnects
 	options.Token = accessToken
 
+	auth, err := readAuthOptions()
+	if err != nil {
+		return err
+	}
+
+	tlsOpts := &gravity.TLSOptions{
+		Enabled:  viper.GetBool("gravity.tls.enabled"),
+		CAFile:   viper.GetString("gravity.tls.caFile"),
+		CertFile: viper.GetString("gravity.tls.certFile"),
+		KeyFile:  viper.GetString("gravity.tls.keyFile"),
+	}
+
 	address := fmt.Sprintf("%s:%d", host, port)
 
 	log.WithFields(log.Fields{
@@ -49,11 +72,14 @@ func (a *AppInstance) initAdapterConnector() error {
 		"pingInterval":        options.PingInterval,
 		"maxPingsOutstanding": options.MaxPingsOutstanding,
 		"maxReconnects":       options.MaxReconnects,
+		"credsFile":           auth.CredsFile,
+		"nkeySeedFile":        auth.NKeySeedFile,
+		"username":            auth.Username,
+		"tls":                 tlsOpts.Enabled || len(tlsOpts.CAFile) > 0 || len(tlsOpts.CertFile) > 0,
 	}).Info("Connecting to gravity...")
 
 	// Connect to gravity
-	client := core.NewClient()
-	err := client.Connect(address, options)
+	conn, err := gravity.Connect(address, options, auth, tlsOpts)
 	if err != nil {
 		return err
 	}
@@ -63,8 +89,7 @@ func (a *AppInstance) initAdapterConnector() error {
 	opts.Domain = domain
 	opts.Compression = gravity_adapter.S2Compression
 
-	a.adapterConnector = gravity_adapter.NewAdapterConnectorWithClient(client, opts)
-	err = a.adapterConnector.Connect(address, options)
+	a.adapterConnector, err = gravity.NewAdapterConnector(conn, options, opts)
 	if err != nil {
 		return err
 	}
@@ -72,6 +97,65 @@ func (a *AppInstance) initAdapterConnector() error {
 	return nil
 }
 
-func (a *AppInstance) GetAdapterConnector() *gravity_adapter.AdapterConnector {
+func stateStoreNeedsGravity() bool {
+	return adapter_service.StateStoreType() == adapter_service.JetStreamStateStore
+}
+
+func readAuthOptions() (*gravity.AuthOptions, error) {
+
+	auth := &gravity.AuthOptions{
+		Username:     viper.GetString("gravity.username"),
+		Password:     viper.GetString("gravity.password"),
+		CredsFile:    viper.GetString("gravity.credsFile"),
+		NKeySeedFile: viper.GetString("gravity.nkeySeedFile"),
+	}
+
+	// Password might be encrypted
+	if strings.HasPrefix(auth.Password, adapter_service.EncryptedSecretPrefix) {
+		pwd, err := adapter_service.DecryptSecret(auth.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		auth.Password = pwd
+	}
+
+	return auth, nil
+}
+
+func (a *AppInstance) GetAdapterConnector() *gravity.AdapterConnector {
 	return a.adapterConnector
 }
+
+// GetDomainConnector returns connector of domain, connectors are shared by sources
+// which publish to the same domain.
+func (a *AppInstance) GetDomainConnector(domain string) (*gravity.AdapterConnector, error) {
+
+	if a.adapterConnector == nil {
+		return nil, nil
+	}
+
+	if len(domain) == 0 || domain == a.adapterConnector.GetDomain() {
+		return a.adapterConnector, nil
+	}
+
+	a.domainMutex.Lock()
+	defer a.domainMutex.Unlock()
+
+	if connector, ok := a.domainConnectors[domain]; ok {
+		return connector, nil
+	}
+
+	connector, err := a.adapterConnector.ForDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"domain": domain,
+	}).Info("Initialized connector for domain")
+
+	a.domainConnectors[domain] = connector
+
+	return connector, nil
+}
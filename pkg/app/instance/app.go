@@ -5,17 +5,26 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"sync"
 	"syscall"
 
 	adapter_service "git.brobridge.com/gravity/gravity-adapter-postgres/pkg/adapter/service"
-	gravity_adapter "github.com/BrobridgeOrg/gravity-sdk/v2/adapter"
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/gravity"
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/monitor"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultMonitorAddress = ":9090"
 )
 
 type AppInstance struct {
 	done             chan os.Signal
 	adapter          *adapter_service.Adapter
-	adapterConnector *gravity_adapter.AdapterConnector
+	adapterConnector *gravity.AdapterConnector
+	domainMutex      sync.Mutex
+	domainConnectors map[string]*gravity.AdapterConnector
 }
 
 func NewAppInstance() *AppInstance {
@@ -24,7 +33,8 @@ func NewAppInstance() *AppInstance {
 	signal.Notify(sig, os.Interrupt, os.Kill, syscall.SIGTERM)
 
 	a := &AppInstance{
-		done: sig,
+		done:             sig,
+		domainConnectors: make(map[string]*gravity.AdapterConnector),
 	}
 
 	a.adapter = adapter_service.NewAdapter(a)
@@ -38,6 +48,13 @@ func (a *AppInstance) Init() error {
 		"max_procs": runtime.GOMAXPROCS(0),
 	}).Info("Starting application")
 
+	// Serving metrics and health checks
+	viper.SetDefault("monitor.enabled", false)
+	viper.SetDefault("monitor.address", DefaultMonitorAddress)
+	if viper.GetBool("monitor.enabled") {
+		monitor.Start(viper.GetString("monitor.address"))
+	}
+
 	// Initializing adapter connector
 	err := a.initAdapterConnector()
 	if err != nil {
@@ -60,6 +77,62 @@ func (a *AppInstance) Run() error {
 
 	<-a.done
 	a.Uninit()
-	fmt.Println("Bye!")
+	fmt.Fprintln(os.Stderr, "Bye!")
+	return nil
+}
+
+// OpenStateStore opens state store without starting adapter
+func (a *AppInstance) OpenStateStore() (adapter_service.StateStore, error) {
+
+	if adapter_service.StateStoreType() == adapter_service.JetStreamStateStore {
+		err := a.initAdapterConnector()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return adapter_service.OpenStateStore(a)
+}
+
+func (a *AppInstance) Snapshot(sourceName string, tableName string) error {
+
+	// Initializing adapter connector
+	err := a.initAdapterConnector()
+	if err != nil {
+		return err
+	}
+
+	err = a.adapter.Snapshot(sourceName, tableName)
+	if err != nil {
+		return err
+	}
+
+	a.Uninit()
+
+	return nil
+}
+
+func (a *AppInstance) Replay(sourceName string, filename string) error {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	// Initializing adapter connector
+	err = a.initAdapterConnector()
+	if err != nil {
+		return err
+	}
+
+	err = a.adapter.Replay(sourceName, f)
+	if err != nil {
+		return err
+	}
+
+	a.Uninit()
+
 	return nil
 }
@@ -1,9 +1,10 @@
 package app
 
 import (
-	gravity_adapter "github.com/BrobridgeOrg/gravity-sdk/v2/adapter"
+	"git.brobridge.com/gravity/gravity-adapter-postgres/pkg/gravity"
 )
 
 type App interface {
-	GetAdapterConnector() *gravity_adapter.AdapterConnector
+	GetAdapterConnector() *gravity.AdapterConnector
+	GetDomainConnector(domain string) (*gravity.AdapterConnector, error)
 }
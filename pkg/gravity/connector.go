@@ -0,0 +1,109 @@
+package gravity
+
+import (
+	"fmt"
+
+	gravity_adapter "github.com/BrobridgeOrg/gravity-sdk/v2/adapter"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/core"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/nats.go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const (
+	domainEvent = "$GVT.%s.EVENT.%s"
+)
+
+// AdapterConnector publishes events to gravity in the same way as gravity SDK does,
+// but on a connection which supports all authentication methods of NATS.
+type AdapterConnector struct {
+	conn        *nats.Conn
+	js          nats.JetStreamContext
+	coreOptions *core.Options
+	options     *gravity_adapter.Options
+}
+
+func Connect(host string, options *core.Options, auth *AuthOptions, t *TLSOptions) (*nats.Conn, error) {
+
+	opts, err := NatsOptions(options, auth, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return nats.Connect(host, opts...)
+}
+
+func NewAdapterConnector(conn *nats.Conn, options *core.Options, opts *gravity_adapter.Options) (*AdapterConnector, error) {
+
+	js, err := conn.JetStream(nats.PublishAsyncMaxPending(options.PublishAsyncMaxPending))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdapterConnector{
+		conn:        conn,
+		js:          js,
+		coreOptions: options,
+		options:     opts,
+	}, nil
+}
+
+// ForDomain creates connector which publishes to another domain on the same connection.
+// It has its own JetStream context, so acknowledgements are tracked separately.
+func (ac *AdapterConnector) ForDomain(domain string) (*AdapterConnector, error) {
+
+	opts := *ac.options
+	opts.Domain = domain
+
+	return NewAdapterConnector(ac.conn, ac.coreOptions, &opts)
+}
+
+func (ac *AdapterConnector) Disconnect() {
+	ac.conn.Close()
+}
+
+func (ac *AdapterConnector) GetDomain() string {
+	return ac.options.Domain
+}
+
+func (ac *AdapterConnector) PublishAsync(eventName string, payload []byte, meta map[string]string) (nats.PubAckFuture, error) {
+
+	msg := &gravity_adapter.Message{
+		EventName: eventName,
+		Payload:   payload,
+	}
+
+	data, _ := json.Marshal(msg)
+
+	m := &nats.Msg{
+		Subject: fmt.Sprintf(domainEvent, ac.options.Domain, eventName),
+		Header:  nats.Header{},
+	}
+
+	for k, v := range meta {
+		m.Header.Add(k, v)
+	}
+
+	if ac.options.Compression == gravity_adapter.S2Compression {
+		m.Header.Add("Content-Encoding", "s2")
+		m.Data = s2.EncodeSnappyBetter(nil, data)
+	} else {
+		m.Data = data
+	}
+
+	return ac.js.PublishMsgAsync(m)
+}
+
+func (ac *AdapterConnector) PublishAsyncComplete() <-chan struct{} {
+	return ac.js.PublishAsyncComplete()
+}
+
+func (ac *AdapterConnector) Subscribe(subject string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	return ac.conn.Subscribe(subject, handler)
+}
+
+func (ac *AdapterConnector) GetJetStream() nats.JetStreamContext {
+	return ac.js
+}
@@ -0,0 +1,123 @@
+package gravity
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/BrobridgeOrg/gravity-sdk/v2/core"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ConflictAuthErr = errors.New("Only one of creds file, nkey seed and user/password can be used")
+	InvalidTLSErr   = errors.New("TLS client certificate and key must be set together")
+)
+
+type AuthOptions struct {
+	Username     string
+	Password     string
+	CredsFile    string
+	NKeySeedFile string
+}
+
+type TLSOptions struct {
+	Enabled  bool
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+func (auth *AuthOptions) Validate() error {
+
+	methods := 0
+	if len(auth.CredsFile) > 0 {
+		methods++
+	}
+
+	if len(auth.NKeySeedFile) > 0 {
+		methods++
+	}
+
+	if len(auth.Username) > 0 {
+		methods++
+	}
+
+	if methods > 1 {
+		return ConflictAuthErr
+	}
+
+	return nil
+}
+
+func (t *TLSOptions) Validate() error {
+
+	if (len(t.CertFile) > 0) != (len(t.KeyFile) > 0) {
+		return InvalidTLSErr
+	}
+
+	return nil
+}
+
+// NatsOptions converts gravity options to options for connecting to NATS server
+func NatsOptions(options *core.Options, auth *AuthOptions, t *TLSOptions) ([]nats.Option, error) {
+
+	opts := []nats.Option{
+		nats.RetryOnFailedConnect(true),
+		nats.PingInterval(options.PingInterval),
+		nats.MaxPingsOutstanding(options.MaxPingsOutstanding),
+		nats.MaxReconnects(options.MaxReconnects),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			options.ReconnectHandler()
+		}),
+		nats.DisconnectErrHandler(func(*nats.Conn, error) {
+			options.DisconnectHandler()
+		}),
+	}
+
+	// Authentication
+	if auth != nil {
+		err := auth.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(auth.CredsFile) > 0 {
+			opts = append(opts, nats.UserCredentials(auth.CredsFile))
+		}
+
+		if len(auth.NKeySeedFile) > 0 {
+			opt, err := nats.NkeyOptionFromSeed(auth.NKeySeedFile)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load nkey seed: %v", err)
+			}
+
+			opts = append(opts, opt)
+		}
+
+		if len(auth.Username) > 0 {
+			opts = append(opts, nats.UserInfo(auth.Username, auth.Password))
+		}
+	}
+
+	// TLS
+	if t != nil {
+		err := t.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Enabled {
+			opts = append(opts, nats.Secure())
+		}
+
+		if len(t.CAFile) > 0 {
+			opts = append(opts, nats.RootCAs(t.CAFile))
+		}
+
+		if len(t.CertFile) > 0 {
+			opts = append(opts, nats.ClientCert(t.CertFile, t.KeyFile))
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	healthMutex sync.RWMutex
+	unready     = make(map[string]string)
+)
+
+// SetUnready marks component as not ready with reason, readiness fails until SetReady is called
+func SetUnready(component string, reason string) {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+	unready[component] = reason
+}
+
+func SetReady(component string) {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+	delete(unready, component)
+}
+
+// Ready returns reasons of components which are not ready
+func Ready() (bool, []string) {
+
+	healthMutex.RLock()
+	defer healthMutex.RUnlock()
+
+	reasons := make([]string, 0, len(unready))
+	for component, reason := range unready {
+		reasons = append(reasons, component+": "+reason)
+	}
+
+	sort.Strings(reasons)
+
+	return len(reasons) == 0, reasons
+}
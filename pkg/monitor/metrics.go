@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	gaugeType   = "gauge"
+	counterType = "counter"
+)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]*Metric)
+)
+
+// Metric is a series of values which share the same name and label names,
+// it is exported in Prometheus text format.
+type Metric struct {
+	mutex      sync.RWMutex
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	values     map[string]float64
+	labels     map[string][]string
+}
+
+func register(name string, help string, metricType string, labelNames []string) *Metric {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	// Returning the same metric to callers which register it again
+	if m, ok := registry[name]; ok {
+		return m
+	}
+
+	m := &Metric{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+
+	registry[name] = m
+
+	return m
+}
+
+// NewGauge registers metric which can go up and down
+func NewGauge(name string, help string, labelNames ...string) *Metric {
+	return register(name, help, gaugeType, labelNames)
+}
+
+// NewCounter registers metric which only goes up
+func NewCounter(name string, help string, labelNames ...string) *Metric {
+	return register(name, help, counterType, labelNames)
+}
+
+func (m *Metric) key(labelValues []string) string {
+
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values", m.name, len(m.labelNames)))
+	}
+
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *Metric) Set(value float64, labelValues ...string) {
+
+	k := m.key(labelValues)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.values[k] = value
+	m.labels[k] = labelValues
+}
+
+func (m *Metric) Add(value float64, labelValues ...string) {
+
+	k := m.key(labelValues)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.values[k] += value
+	m.labels[k] = labelValues
+}
+
+func (m *Metric) Inc(labelValues ...string) {
+	m.Add(1, labelValues...)
+}
+
+func (m *Metric) Get(labelValues ...string) float64 {
+
+	k := m.key(labelValues)
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.values[k]
+}
+
+// Delete removes series, it is used when source or table is gone
+func (m *Metric) Delete(labelValues ...string) {
+
+	k := m.key(labelValues)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.values, k)
+	delete(m.labels, k)
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func (m *Metric) write(w io.Writer) {
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.metricType)
+
+	keys := make([]string, 0, len(m.values))
+	for k, _ := range m.values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value := strconv.FormatFloat(m.values[k], 'g', -1, 64)
+
+		if len(m.labelNames) == 0 {
+			fmt.Fprintf(w, "%s %s\n", m.name, value)
+			continue
+		}
+
+		pairs := make([]string, len(m.labelNames))
+		for i, name := range m.labelNames {
+			pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(m.labels[k][i]))
+		}
+
+		fmt.Fprintf(w, "%s{%s} %s\n", m.name, strings.Join(pairs, ","), value)
+	}
+}
+
+// WriteMetrics writes all metrics in Prometheus text format
+func WriteMetrics(w io.Writer) {
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name, _ := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		registry[name].write(w)
+	}
+}
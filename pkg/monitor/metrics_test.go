@@ -0,0 +1,53 @@
+package monitor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetrics(t *testing.T) {
+
+	g := NewGauge("test_slot_lag_bytes", "Lag of slot", "source", "slot")
+	g.Set(1024, "my_postgres", "regression_slot")
+	g.Set(2048, "another", "slot\"2")
+
+	c := NewCounter("test_events_total", "Total events")
+	c.Inc()
+	c.Add(2)
+
+	buf := &bytes.Buffer{}
+	WriteMetrics(buf)
+
+	assert.Contains(t, buf.String(), "# TYPE test_slot_lag_bytes gauge\n")
+	assert.Contains(t, buf.String(), `test_slot_lag_bytes{source="my_postgres",slot="regression_slot"} 1024`+"\n")
+	assert.Contains(t, buf.String(), `test_slot_lag_bytes{source="another",slot="slot\"2"} 2048`+"\n")
+	assert.Contains(t, buf.String(), "test_events_total 3\n")
+
+	// Registering again returns the same metric
+	assert.Equal(t, g, NewGauge("test_slot_lag_bytes", "Lag of slot", "source", "slot"))
+}
+
+func TestReady(t *testing.T) {
+
+	SetUnready("slot my_postgres", "wal_status is lost")
+	ready, reasons := Ready()
+	assert.False(t, ready)
+	assert.Equal(t, []string{"slot my_postgres: wal_status is lost"}, reasons)
+
+	SetReady("slot my_postgres")
+	ready, _ = Ready()
+	assert.True(t, ready)
+}
+
+func TestStatus(t *testing.T) {
+
+	SetStatus("my_postgres", func() interface{} {
+		return map[string]interface{}{"paused": false}
+	})
+	assert.Equal(t, map[string]interface{}{"my_postgres": map[string]interface{}{"paused": false}}, Status())
+
+	RemoveStatus("my_postgres")
+	assert.Empty(t, Status())
+}
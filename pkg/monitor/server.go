@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Handler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteMetrics(w)
+	})
+
+	// Process is alive as long as it can serve requests
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready, reasons := Ready()
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(reasons, "\n"))
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(Status())
+		if err != nil {
+			log.Error("monitor: ", err)
+		}
+	})
+
+	return mux
+}
+
+// Start serves metrics and health checks in background
+func Start(address string) {
+
+	log.WithFields(log.Fields{
+		"address": address,
+	}).Info("Starting monitor server")
+
+	go func() {
+		err := http.ListenAndServe(address, Handler())
+		if err != nil {
+			log.Error("monitor: ", err)
+		}
+	}()
+}
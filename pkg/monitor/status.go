@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	"sync"
+)
+
+var (
+	statusMutex     sync.RWMutex
+	statusProviders = make(map[string]func() interface{})
+)
+
+// SetStatus registers function which reports status of component, it is served at /status
+func SetStatus(component string, fn func() interface{}) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	statusProviders[component] = fn
+}
+
+func RemoveStatus(component string) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	delete(statusProviders, component)
+}
+
+// Status returns status of all components
+func Status() map[string]interface{} {
+
+	statusMutex.RLock()
+	defer statusMutex.RUnlock()
+
+	status := make(map[string]interface{}, len(statusProviders))
+	for component, fn := range statusProviders {
+		status[component] = fn()
+	}
+
+	return status
+}